@@ -0,0 +1,56 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package amdpstate
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+const (
+	statusFile = "/sys/devices/system/cpu/amd_pstate/status"
+
+	// Active indicates amd_pstate runs autonomously, with the firmware
+	// selecting frequencies based on the energy performance preference (EPP).
+	Active = "active"
+	// Passive indicates amd_pstate follows the frequency requested by the
+	// cpufreq governor.
+	Passive = "passive"
+	// Guided indicates the governor sets a frequency range, within which the
+	// firmware selects the frequency autonomously.
+	Guided = "guided"
+	// Disabled indicates the amd_pstate driver is loaded but not in use.
+	Disabled = "disable"
+)
+
+// Available returns a boolean indicating whether the amd_pstate driver exposes
+// its operation mode. Older kernels only support it in passive mode, and do
+// not have the status file.
+func Available() bool {
+	if _, err := os.Stat(statusFile); err == nil {
+		return true
+	}
+	return false
+}
+
+// Mode returns the amd_pstate operation mode: Active, Passive, Guided or
+// Disabled.
+func Mode() (string, error) {
+	value, err := ioutil.ReadFile(statusFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(value)), nil
+}
@@ -0,0 +1,277 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/template"
+	"time"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/cpuinfo"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/setting"
+)
+
+// Possible results of applying a value to a setting.
+const (
+	resultChanged     = "changed"
+	resultAlreadySet  = "already set"
+	resultFailed      = "failed"
+	resultUnavailable = "unavailable"
+	resultSkipped     = "skipped"
+)
+
+var (
+	// formatTemplate, if set, is used to report the result of each action
+	// instead of the default human-readable format. It is executed with an
+	// applyResult, so it can refer to fields such as {{.Setting}},
+	// {{.Action}} and {{.Result}}.
+	formatTemplate *template.Template
+
+	// quietSuccess indicates that, if nothing changed and nothing failed,
+	// only a single line should be printed. Otherwise, the results are
+	// printed once every setting was applied.
+	quietSuccess = false
+
+	// applyRepeat is how many times in a row each setting is applied, as a
+	// workaround for firmware on which the first MSR write after boot may
+	// not stick. applyRepeatDelay is the pause between those attempts.
+	applyRepeat      = 1
+	applyRepeatDelay = 100 * time.Millisecond
+)
+
+// applyResult is the outcome of applying a value to a single setting.
+type applyResult struct {
+	Setting string `json:"setting"`
+	Action  string `json:"action"`
+	Value   string `json:"value"`
+	Result  string `json:"result"`
+	Error   string `json:"error,omitempty"`
+	// Previous is the status of the setting before applying, if it could
+	// be read.
+	Previous string `json:"previous,omitempty"`
+	// DurationMs is how long applying took, in milliseconds.
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// applySummary counts the results of applying a set of values.
+type applySummary struct {
+	Changed    int `json:"changed"`
+	AlreadySet int `json:"already_set"`
+	Failed     int `json:"failed"`
+	Skipped    int `json:"skipped"`
+}
+
+// applyReport collects the results of applying a set of values to settings.
+type applyReport struct {
+	Results []applyResult `json:"results"`
+	Summary applySummary  `json:"summary"`
+	// DurationMs is how long applying every setting took, in milliseconds.
+	DurationMs float64 `json:"duration_ms"`
+	// Hook is the outcome of the post-apply hook, if one ran.
+	Hook *hookResult `json:"post_apply,omitempty"`
+}
+
+// reportProgress returns whether the outcome of each setting should be
+// reported as it happens, which is the default human-readable format.
+func reportProgress() bool {
+	return !jsonOutput && formatTemplate == nil && !quietSuccess
+}
+
+// milliseconds converts a duration to milliseconds, for reporting.
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// apply changes the given setting to the provided value, if it is available,
+// and records the outcome. Unless we are producing JSON output or using a
+// custom format template, the outcome is also reported as it happens.
+func (r *applyReport) apply(s setting.Setting, value string) {
+	progress := reportProgress()
+	// Timing starts once the value is confirmed, so that waiting for the
+	// user does not count.
+	var start time.Time
+	result := applyResult{
+		Setting: s.Name(),
+		Action:  actionDescription(s, value),
+		Value:   value,
+	}
+	defer func() {
+		if !start.IsZero() {
+			result.DurationMs = milliseconds(time.Since(start))
+		}
+		r.record(result)
+	}()
+
+	if err := s.Available(); err != nil {
+		result.Result = resultUnavailable
+		result.Error = err.Error()
+		if progress {
+			fmt.Printf("%s unavailable - %v.\n", capitalize(s.Description()), err)
+			// The family may also be misreported by the firmware.
+			if errors.Is(err, cpuinfo.ErrFamilyUnsupported) {
+				fmt.Println("If the processor family is misdetected, see -assume-family.")
+			}
+		}
+		return
+	}
+
+	if !confirm(s, value) {
+		result.Result = resultSkipped
+		if forceRequired(s, value) {
+			result.Error = "requires -force"
+		}
+		if progress {
+			if result.Error != "" {
+				fmt.Printf("%s:   SKIPPED (%s)\n", result.Action, result.Error)
+			} else {
+				fmt.Printf("%s:   SKIPPED\n", result.Action)
+			}
+		}
+		return
+	}
+
+	start = time.Now()
+	if progress {
+		fmt.Printf("%s:   ", result.Action)
+	}
+
+	// The value is still applied when the setting already has it, but we
+	// report it separately.
+	applied := applyRepeatedly(s, value)
+	result.Previous = applied.Previous
+	if applied.Err != nil {
+		result.Result = resultFailed
+		result.Error = applied.Err.Error()
+		if progress {
+			fmt.Printf("oops: %v\n", applied.Err)
+		}
+		return
+	}
+
+	result.Result = resultChanged
+	alreadySet := !applied.Changed
+	if alreadySet {
+		result.Result = resultAlreadySet
+	}
+	if progress {
+		if alreadySet {
+			fmt.Println("SUCCESS (already set)")
+		} else {
+			fmt.Println("SUCCESS")
+		}
+	}
+}
+
+// applyRepeatedly applies value to s applyRepeat times, applyRepeatDelay
+// apart, stopping at the first failure. The outcome is the one of the first
+// attempt, with the error of the failed one, if any.
+func applyRepeatedly(s setting.Setting, value string) setting.Result {
+	// The value was confirmed already, if needed; see confirm.
+	result := setting.ApplyConfirmed(s, value)
+	if result.Err != nil && applyRepeat > 1 {
+		result.Err = fmt.Errorf("attempt 1 of %d: %v", applyRepeat, result.Err)
+	}
+	for attempt := 2; attempt <= applyRepeat && result.Err == nil; attempt++ {
+		time.Sleep(applyRepeatDelay)
+		if err := s.Apply(value); err != nil {
+			result.Err = fmt.Errorf("attempt %d of %d: %v", attempt, applyRepeat, err)
+		}
+	}
+	return result
+}
+
+// skip records that the given setting was not applied, for the given reason.
+func (r *applyReport) skip(s setting.Setting, value, reason string) {
+	result := applyResult{
+		Setting: s.Name(),
+		Action:  actionDescription(s, value),
+		Value:   value,
+		Result:  resultSkipped,
+		Error:   reason,
+	}
+	if reportProgress() {
+		fmt.Printf("%s:   SKIPPED (%s)\n", result.Action, reason)
+	}
+	r.record(result)
+}
+
+// record adds a result to the report, updating the summary. When using a
+// custom format template, this is also when the result is reported.
+func (r *applyReport) record(result applyResult) {
+	r.Results = append(r.Results, result)
+	r.DurationMs += result.DurationMs
+	if verbose && reportProgress() && result.DurationMs > 0 {
+		fmt.Printf("  (%s took %.1f ms)\n", result.Setting, result.DurationMs)
+	}
+	if formatTemplate != nil && !jsonOutput {
+		if err := formatTemplate.Execute(os.Stdout, result); err != nil {
+			fmt.Printf("Error: unable to format result for %s: %v.", result.Setting, err)
+		}
+		fmt.Println()
+	}
+
+	switch result.Result {
+	case resultChanged:
+		r.Summary.Changed++
+	case resultAlreadySet:
+		r.Summary.AlreadySet++
+	case resultSkipped:
+		r.Summary.Skipped++
+	default:
+		r.Summary.Failed++
+	}
+}
+
+// String returns the one-line verdict for the summary.
+func (s applySummary) String() string {
+	changes := "changes"
+	if s.Changed == 1 {
+		changes = "change"
+	}
+	summary := fmt.Sprintf("Applied %d %s, %d already set, %d failed", s.Changed, changes, s.AlreadySet, s.Failed)
+	if s.Skipped > 0 {
+		summary += fmt.Sprintf(", %d skipped", s.Skipped)
+	}
+	return summary
+}
+
+// resultLine returns the line describing a result, as printed while applying.
+func resultLine(result applyResult) string {
+	switch result.Result {
+	case resultUnavailable:
+		description := result.Setting
+		if s := setting.Lookup(result.Setting); s != nil {
+			description = s.Description()
+		}
+		return fmt.Sprintf("%s unavailable - %s.", capitalize(description), result.Error)
+	case resultSkipped:
+		if result.Error != "" {
+			return fmt.Sprintf("%s:   SKIPPED (%s)", result.Action, result.Error)
+		}
+		return fmt.Sprintf("%s:   SKIPPED", result.Action)
+	case resultFailed:
+		return fmt.Sprintf("%s:   oops: %s", result.Action, result.Error)
+	case resultAlreadySet:
+		return fmt.Sprintf("%s:   SUCCESS (already set)", result.Action)
+	}
+	return fmt.Sprintf("%s:   SUCCESS", result.Action)
+}
+
+// eventful returns whether applying changed or failed anything, i.e. whether
+// there is something worth reporting in -quiet-success mode.
+func (r *applyReport) eventful() bool {
+	return r.Summary.Changed > 0 || r.Summary.Failed > 0
+}
@@ -14,44 +14,112 @@
 package aslr
 
 import (
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"strconv"
 	"strings"
+	"syscall"
 )
 
 const (
 	aslrControlFile = "/proc/sys/kernel/randomize_va_space"
 )
 
+var (
+	// ErrProcNotMounted indicates the ASLR control file does not exist, which
+	// usually means /proc is not mounted (or is hidden, e.g. in a container).
+	ErrProcNotMounted = errors.New("/proc does not seem to be mounted")
+	// ErrReadOnly indicates /proc is mounted read-only.
+	ErrReadOnly = errors.New("/proc is mounted read-only")
+	// ErrPermission indicates we lack the privileges to change ASLR. Like
+	// every sysctl setting, it needs root, whatever the capabilities.
+	ErrPermission = errors.New("permission denied, you need to be root")
+	// ErrVerify indicates the ASLR mode read back after a change is not the
+	// one we wrote.
+	ErrVerify = errors.New("ASLR mode did not change as requested")
+)
+
+// classifyError maps errors from accessing the ASLR control file to one of
+// ErrProcNotMounted, ErrReadOnly and ErrPermission, since the remedy differs
+// for each. Other errors are returned unchanged.
+func classifyError(err error) error {
+	var cause error
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, syscall.ENOENT):
+		cause = ErrProcNotMounted
+	case errors.Is(err, syscall.EROFS):
+		cause = ErrReadOnly
+	case errors.Is(err, syscall.EACCES), errors.Is(err, syscall.EPERM):
+		cause = ErrPermission
+	default:
+		return err
+	}
+	return fmt.Errorf("%w (%v)", cause, err)
+}
+
+// ASLR modes, following info from https://askubuntu.com/a/318476.
+const (
+	// NoRandomization means everything is static.
+	NoRandomization = 0
+	// ConservativeRandomization means shared libraries, stack, mmap(), VDSO
+	// and heap are randomized.
+	ConservativeRandomization = 1
+	// FullRandomization means, in addition to elements listed in the
+	// previous mode, memory managed through brk() is also randomized.
+	FullRandomization = 2
+)
+
+// Mode returns the current ASLR mode. Reading it requires no privileges.
+func Mode() (int, error) {
+	value, err := ioutil.ReadFile(aslrControlFile)
+	if err != nil {
+		return 0, classifyError(err)
+	}
+	return strconv.Atoi(strings.TrimSpace(string(value)))
+}
+
+// SetMode changes the ASLR mode, which requires root. The mode is read back
+// after the write, and ErrVerify is returned if it does not match, so that a
+// write the kernel did not honor is not reported as a success.
+func SetMode(mode int) error {
+	if mode < NoRandomization || mode > FullRandomization {
+		return fmt.Errorf("invalid ASLR mode %d; expected %d to %d", mode, NoRandomization, FullRandomization)
+	}
+	if err := ioutil.WriteFile(aslrControlFile, []byte(strconv.Itoa(mode)), 0644); err != nil {
+		return classifyError(err)
+	}
+
+	current, err := Mode()
+	if err != nil {
+		return err
+	}
+	if current != mode {
+		return fmt.Errorf("%w: expected %d, got %d", ErrVerify, mode, current)
+	}
+	return nil
+}
+
 // changeASLR receives a parameter indicating whether it should enable or
 // disable address space layout randomization (ASLR).
 func changeASLR(enable bool) error {
-	// Following info from https://askubuntu.com/a/318476.
-	// 0 - no randomization. Everything is static.
-	// 1 - conservative randomization. Shared libraries, stack, mmap(), VDSO and
-	//     heap are randomized.
-	// 2 - full randomization. In addition to elements listed in the previous
-	//     point, memory managed through brk() is also randomized.
 	// We enable by setting full randomization (2), and disable with no
 	// randomization (0).
-	value := []byte("0")
 	if enable {
-		value = []byte("2")
+		return SetMode(FullRandomization)
 	}
-	return ioutil.WriteFile(aslrControlFile, value, 0644)
+	return SetMode(NoRandomization)
 }
 
 // Enabled returns a boolean indicating whether ASLR is enabled or not.
 func Enabled() (bool, error) {
-	value, err := ioutil.ReadFile(aslrControlFile)
+	mode, err := Mode()
 	if err != nil {
 		return false, err
 	}
-
-	enabled := true
-	if strings.Trim(string(value), "\n") == "0" {
-		enabled = false
-	}
-	return enabled, nil
+	return mode != NoRandomization, nil
 }
 
 // Disabled returns a boolean indicating whether ASLR is disabled.
@@ -0,0 +1,37 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aslr
+
+import (
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/setting"
+)
+
+func init() {
+	// ASLR is always available, so no Availability is needed.
+	setting.Register(&setting.Toggle{
+		Key:       "aslr",
+		Label:     "address space layout randomization (ASLR)",
+		Enable:    Enable,
+		Disable:   Disable,
+		IsEnabled: Enabled,
+		// Unlike the other settings, the kernel can apply it at boot.
+		Persists: "lost at reboot, unless set in /etc/sysctl.d (kernel.randomize_va_space)",
+		// The kernel defaults to full randomization, i.e. 2.
+		BootDefault: setting.Enabled,
+		Touches:     aslrControlFile,
+		// norandmaps turns ASLR off, as would randomize_va_space=0.
+		KernelParams: []string{"norandmaps"},
+		Order:        setting.OrderASLR,
+	})
+}
@@ -0,0 +1,278 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/boosting"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/cppc"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/cpulist"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/pstate"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/setting"
+)
+
+const (
+	// benchmarkSampleInterval is how often the frequency is sampled during
+	// the busy loop.
+	benchmarkSampleInterval = 50 * time.Millisecond
+	// maxBenchmarkDuration bounds each busy loop, to keep the benchmark
+	// brief.
+	maxBenchmarkDuration = 10 * time.Second
+	// boostMargin is how far above the base frequency the frequency under
+	// load must be to count as boosting, as sampling is not exact.
+	boostMargin = 50
+)
+
+var (
+	benchmarkCPUOnce sync.Once
+	benchmarkCPUID   int
+)
+
+// benchmarkCPU returns the CPU the busy loop runs on: the first of those
+// per-core operations act on, so that, unless -all-host-cpus is given, it is
+// one we are allowed to run on, e.g. when the cpuset of a container excludes
+// cpu0. It is chosen once, so that every measurement is of the same CPU.
+func benchmarkCPU() int {
+	benchmarkCPUOnce.Do(func() {
+		if cpus := cpulist.CPUs(); len(cpus) > 0 {
+			benchmarkCPUID = cpus[0]
+		}
+	})
+	return benchmarkCPUID
+}
+
+// onBenchmarkCPU runs fn on benchmarkCPU. The thread is allowed back on its
+// original CPUs afterwards, as the runtime reuses it for other goroutines.
+func onBenchmarkCPU(fn func() error) (err error) {
+	// The busy loop must stay on benchmarkCPU.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	tid := syscall.Gettid()
+	original, err := threadAffinity(tid)
+	if err != nil {
+		return fmt.Errorf("unable to obtain the CPUs we run on: %v", err)
+	}
+	if err := setThreadAffinity(tid, []int{benchmarkCPU()}); err != nil {
+		return fmt.Errorf("unable to run on cpu%d: %v", benchmarkCPU(), err)
+	}
+	defer func() {
+		if restoreErr := setThreadAffinity(tid, original); restoreErr != nil && err == nil {
+			err = fmt.Errorf("unable to run on the original CPUs again: %v", restoreErr)
+		}
+	}()
+	return fn()
+}
+
+// measureFrequency keeps benchmarkCPU busy for the given duration and returns
+// its average frequency, in MHz, in the meantime, as sampled from cpufreq.
+func measureFrequency(duration time.Duration) (int, error) {
+	var total, samples int
+	err := onBenchmarkCPU(func() error {
+		deadline := time.Now().Add(duration)
+		next := time.Now().Add(benchmarkSampleInterval)
+		for now := time.Now(); now.Before(deadline); now = time.Now() {
+			if now.Before(next) {
+				continue
+			}
+			freq, err := boosting.CurrentFrequency(benchmarkCPU())
+			if err != nil {
+				return err
+			}
+			total += freq
+			samples++
+			next = now.Add(benchmarkSampleInterval)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if samples == 0 {
+		return 0, fmt.Errorf("benchmark too short to sample the frequency")
+	}
+	return total / samples, nil
+}
+
+// measureEffectiveFrequency keeps benchmarkCPU busy for the given duration and
+// returns its effective frequency, in MHz, in the meantime, from the APERF and
+// MPERF MSRs and the base frequency, in MHz, at which MPERF counts.
+func measureEffectiveFrequency(duration time.Duration, base int) (int, error) {
+	var mperf, aperf uint64
+	err := onBenchmarkCPU(func() error {
+		mperfBefore, aperfBefore, err := boosting.Counters(benchmarkCPU())
+		if err != nil {
+			return err
+		}
+		deadline := time.Now().Add(duration)
+		for time.Now().Before(deadline) {
+			// Busy, so that the CPU stays in C0.
+		}
+		mperfAfter, aperfAfter, err := boosting.Counters(benchmarkCPU())
+		if err != nil {
+			return err
+		}
+		mperf, aperf = mperfAfter-mperfBefore, aperfAfter-aperfBefore
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if mperf == 0 {
+		return 0, fmt.Errorf("MPERF did not advance")
+	}
+	return int(uint64(base) * aperf / mperf), nil
+}
+
+// benchmark measures the frequency under load, toggles processor boosting,
+// measures it again and displays the difference, so that the effect of the
+// setting can be seen. Processor boosting is restored afterwards, or when we
+// are interrupted or terminated in the meantime.
+func benchmark(duration time.Duration) error {
+	if duration > maxBenchmarkDuration {
+		duration = maxBenchmarkDuration
+	}
+	s := setting.Lookup("boosting")
+	if err := s.Available(); err != nil {
+		return fmt.Errorf("%s unavailable - %v", s.Description(), err)
+	}
+	original, err := s.Status()
+	if err != nil {
+		return err
+	}
+	toggled := setting.Enabled
+	if original == setting.Enabled {
+		toggled = setting.Disabled
+	}
+
+	before, err := measureFrequency(duration)
+	if err != nil {
+		return err
+	}
+	stop := restoreOnSignal(s, original)
+	if err := s.Apply(toggled); err != nil {
+		stop()
+		return err
+	}
+	after, measureErr := measureFrequency(duration)
+	stop()
+	if err := s.Apply(original); err != nil {
+		return fmt.Errorf("unable to restore %s to %s: %v", s.Description(), original, err)
+	}
+	if measureErr != nil {
+		return measureErr
+	}
+
+	fmt.Printf("Effective frequency of cpu%d under load, over %v:\n", benchmarkCPU(), duration)
+	fmt.Printf("  with %s %s: %d MHz\n", s.Description(), strings.ToUpper(original), before)
+	fmt.Printf("  with %s %s: %d MHz\n", s.Description(), strings.ToUpper(toggled), after)
+	fmt.Printf("Difference: %+d MHz.\n", after-before)
+	return nil
+}
+
+// restoreOnSignal restores the setting s to original and exits if we are
+// interrupted or terminated, so that the benchmark does not leave it toggled,
+// until the returned function is called.
+func restoreOnSignal(s setting.Setting, original string) func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-signals:
+			if err := s.Apply(original); err != nil {
+				fmt.Printf("\nError: interrupted by %v, and unable to restore %s to %s: %v.\n", sig, s.Description(), original, err)
+			} else {
+				fmt.Printf("\nInterrupted by %v; restored %s to %s.\n", sig, s.Description(), original)
+			}
+			os.Exit(1)
+		case <-done:
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// baseFrequency returns the base frequency of benchmarkCPU, in MHz, above
+// which it is boosting: the frequency of P-state P0 or, if the P-states cannot
+// be read, e.g. without root, the nominal frequency reported by CPPC.
+func baseFrequency() (int, error) {
+	if pstate.Available() == nil {
+		if p, err := pstate.Read(0, benchmarkCPU()); err == nil && p.Enabled && p.Frequency() > 0 {
+			return p.Frequency(), nil
+		}
+	}
+	if cppc.Available() {
+		if freq, err := cppc.NominalFrequency(benchmarkCPU()); err == nil && freq > 0 {
+			return int(freq), nil
+		}
+	}
+	return 0, fmt.Errorf("unable to obtain the base frequency from P-state P0 or CPPC")
+}
+
+// probeBoost checks whether processor boosting actually happens, as its
+// control only tells whether it is allowed, e.g. not whether the firmware
+// locked it: it keeps benchmarkCPU busy for the given duration and compares
+// its effective frequency, from the APERF and MPERF MSRs, to the base one,
+// warning if the outcome contradicts the control.
+func probeBoost(duration time.Duration) error {
+	if duration > maxBenchmarkDuration {
+		duration = maxBenchmarkDuration
+	}
+	s := setting.Lookup("boosting")
+	if err := s.Available(); err != nil {
+		return fmt.Errorf("%s unavailable - %v", s.Description(), err)
+	}
+	control, err := s.Status()
+	if err != nil {
+		return err
+	}
+	base, err := baseFrequency()
+	if err != nil {
+		return err
+	}
+	// cpufreq may report the requested frequency, or one sampled while
+	// idle, so the effective one is measured when the MSRs can be read.
+	freq, err := measureEffectiveFrequency(duration, base)
+	if err != nil {
+		fmt.Printf("Note: unable to read the APERF and MPERF MSRs (%v); sampling the frequency from cpufreq instead.\n", err)
+		if freq, err = measureFrequency(duration); err != nil {
+			return err
+		}
+	}
+
+	observed := freq > base+boostMargin
+	answer := "no"
+	if observed {
+		answer = "yes"
+	}
+	fmt.Printf("%s is %s; under load, cpu%d ran at %d MHz over %v, with a base frequency of %d MHz.\n", capitalize(s.Description()), strings.ToUpper(control), benchmarkCPU(), freq, duration, base)
+	fmt.Printf("Boost observed: %s.\n", answer)
+	switch {
+	case control == setting.Enabled && !observed:
+		fmt.Println("Warning: boosting is enabled, but did not happen; it may be locked by the firmware, or held back by power or thermal limits, or by other load.")
+	case control == setting.Disabled && observed:
+		fmt.Println("Warning: boosting is disabled, but still happened; the firmware may not honor the control.")
+	}
+	return nil
+}
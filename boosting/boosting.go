@@ -14,13 +14,24 @@
 package boosting
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
+	"strconv"
 	"strings"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/cpuinfo"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/msr"
 )
 
 const (
 	boostingControlFile = "/sys/devices/system/cpu/cpufreq/boost"
+	pstateMaxFreqFile   = "/sys/devices/system/cpu/cpu0/cpufreq/amd_pstate_max_freq"
+
+	// mperfMSR counts at the base frequency, i.e. the one of P-state P0,
+	// and aperfMSR at the actual one, while the CPU is in C0.
+	mperfMSR = 0xE7
+	aperfMSR = 0xE8
 )
 
 // changeProcessorBoosting receives a parameter indicating whether it should
@@ -79,3 +90,54 @@ func Disable() error {
 	// We pass `false' to disable boosting.
 	return changeProcessorBoosting(false)
 }
+
+// StockMaxBoost returns the stock maximum boost frequency, in MHz, of the
+// processor: the rated one for its model, or, for models not known, the one
+// amd_pstate derives from CPPC. cpuinfo_max_freq is not used, as it holds the
+// base frequency under acpi-cpufreq, the usual driver on Zen 2 and Zen 3.
+func StockMaxBoost() (int, error) {
+	if boost, err := cpuinfo.RatedBoost(); err == nil {
+		return boost, nil
+	}
+	value, err := ioutil.ReadFile(pstateMaxFreqFile)
+	if os.IsNotExist(err) {
+		return 0, cpuinfo.ErrBoostUnknown
+	}
+	if err != nil {
+		return 0, err
+	}
+	// cpufreq reports frequencies in kHz.
+	khz, err := strconv.Atoi(strings.TrimSpace(string(value)))
+	if err != nil {
+		return 0, err
+	}
+	return khz / 1000, nil
+}
+
+// CurrentFrequency returns the current frequency, in MHz, of the given CPU, as
+// reported by cpufreq.
+func CurrentFrequency(cpu int) (int, error) {
+	value, err := ioutil.ReadFile(fmt.Sprintf("/sys/devices/system/cpu/cpu%d/cpufreq/scaling_cur_freq", cpu))
+	if err != nil {
+		return 0, err
+	}
+	khz, err := strconv.Atoi(strings.TrimSpace(string(value)))
+	if err != nil {
+		return 0, err
+	}
+	return khz / 1000, nil
+}
+
+// Counters returns the MPERF and APERF MSRs of the given CPU. Over an
+// interval, the base frequency times the ratio of the increments of APERF to
+// the ones of MPERF is the effective frequency, unlike the one cpufreq
+// reports, which may be a request or a snapshot.
+func Counters(cpu int) (mperf, aperf uint64, err error) {
+	if mperf, err = msr.Read(mperfMSR, cpu); err != nil {
+		return 0, 0, err
+	}
+	if aperf, err = msr.Read(aperfMSR, cpu); err != nil {
+		return 0, 0, err
+	}
+	return mperf, aperf, nil
+}
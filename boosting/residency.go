@@ -0,0 +1,153 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boosting
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/cpulist"
+)
+
+const (
+	cpuDir = "/sys/devices/system/cpu"
+)
+
+var (
+	// ErrNoStats is returned when the kernel does not provide cpufreq
+	// statistics, i.e. it was built without CONFIG_CPU_FREQ_STAT.
+	ErrNoStats = errors.New("cpufreq statistics unavailable (kernel built without CONFIG_CPU_FREQ_STAT?)")
+)
+
+// CoreResidency is the fraction of time, in percent, that a CPU spent above
+// its base frequency during a sampling interval.
+type CoreResidency struct {
+	CPU     int
+	Percent float64
+}
+
+// baseFrequency returns the base (non-boost) frequency, in kHz, of the given
+// CPU. Depending on the cpufreq driver, this is either reported directly, or
+// as the CPPC nominal frequency, in MHz.
+func baseFrequency(cpu int) (uint64, error) {
+	value, err := ioutil.ReadFile(fmt.Sprintf("%s/cpu%d/cpufreq/base_frequency", cpuDir, cpu))
+	if err == nil {
+		return strconv.ParseUint(strings.TrimSpace(string(value)), 10, 64)
+	}
+	value, err = ioutil.ReadFile(fmt.Sprintf("%s/cpu%d/acpi_cppc/nominal_freq", cpuDir, cpu))
+	if err != nil {
+		return 0, fmt.Errorf("unable to determine base frequency of cpu%d", cpu)
+	}
+	mhz, err := strconv.ParseUint(strings.TrimSpace(string(value)), 10, 64)
+	return mhz * 1000, err
+}
+
+// timeInState returns, for the given CPU, how long it has spent above its base
+// frequency and in total, in the units used by cpufreq (10ms).
+func timeInState(cpu int, base uint64) (boosted, total uint64, err error) {
+	f, err := os.Open(fmt.Sprintf("%s/cpu%d/cpufreq/stats/time_in_state", cpuDir, cpu))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	// Each line has a frequency, in kHz, and the time spent in it.
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		freq, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		t, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		total += t
+		if freq > base {
+			boosted += t
+		}
+	}
+	return boosted, total, scanner.Err()
+}
+
+// statsCPUs returns the CPUs for which cpufreq statistics are available.
+func statsCPUs() ([]int, error) {
+	matches, err := filepath.Glob(cpuDir + "/cpu[0-9]*/cpufreq/stats/time_in_state")
+	if err != nil {
+		return nil, err
+	}
+	var cpus []int
+	for _, m := range matches {
+		name := filepath.Base(filepath.Dir(filepath.Dir(filepath.Dir(m))))
+		cpu, err := strconv.Atoi(strings.TrimPrefix(name, "cpu"))
+		if err != nil || !cpulist.Includes(cpu) {
+			continue
+		}
+		cpus = append(cpus, cpu)
+	}
+	if len(cpus) == 0 {
+		return nil, ErrNoStats
+	}
+	sort.Ints(cpus)
+	return cpus, nil
+}
+
+// BoostResidency samples the cpufreq statistics over the given interval and
+// returns, for each CPU, the percentage of that time spent above the base
+// frequency. It returns ErrNoStats if cpufreq statistics are unavailable.
+func BoostResidency(interval time.Duration) ([]CoreResidency, error) {
+	cpus, err := statsCPUs()
+	if err != nil {
+		return nil, err
+	}
+
+	type sample struct{ base, boosted, total uint64 }
+	before := make([]sample, len(cpus))
+	for i, cpu := range cpus {
+		if before[i].base, err = baseFrequency(cpu); err != nil {
+			return nil, err
+		}
+		if before[i].boosted, before[i].total, err = timeInState(cpu, before[i].base); err != nil {
+			return nil, err
+		}
+	}
+
+	time.Sleep(interval)
+
+	residency := make([]CoreResidency, 0, len(cpus))
+	for i, cpu := range cpus {
+		boosted, total, err := timeInState(cpu, before[i].base)
+		if err != nil {
+			return nil, err
+		}
+		r := CoreResidency{CPU: cpu}
+		if elapsed := total - before[i].total; elapsed > 0 {
+			r.Percent = 100 * float64(boosted-before[i].boosted) / float64(elapsed)
+		}
+		residency = append(residency, r)
+	}
+	return residency, nil
+}
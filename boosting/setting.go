@@ -0,0 +1,43 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boosting
+
+import (
+	"errors"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/setting"
+)
+
+func init() {
+	setting.Register(&setting.Toggle{
+		Key:          "boosting",
+		Label:        "processor boosting",
+		Availability: availability,
+		Enable:       Enable,
+		Disable:      Disable,
+		IsEnabled:    Enabled,
+		BootDefault:  setting.Enabled,
+		Touches:      boostingControlFile,
+		Order:        setting.OrderBoosting,
+	})
+}
+
+// availability explains why processor boosting control is unavailable, if that
+// is the case.
+func availability() error {
+	if !Available() {
+		return errors.New("check if AMD Cool'n'Quiet enabled and cpufreq module loaded")
+	}
+	return nil
+}
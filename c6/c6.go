@@ -14,96 +14,50 @@
 package c6
 
 import (
-	"encoding/binary"
-	"fmt"
-	"os"
-	"runtime"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/msr"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/setting"
 )
 
-// ryzenC6MSR stores the offset and target bit of a given feature. MSR stands
-// for model-specific register.
-type ryzenC6MSR struct {
-	offset int64
-	bit    uint64
-}
-
-var (
-	// msr has info for core and package C6, a C-state (idle power saving
-	// state). Magic numbers for the MSR obtained from ZenStates-Linux project
-	// available at https://github.com/r4m0n/ZenStates-Linux.
-	msr = []ryzenC6MSR{
-		// C6 package.
-		{0xC0010292, 1 << 32},
-		// C6 core.
-		{0xC0010296, (1 << 22) | (1 << 14) | (1 << 6)},
+// applyC6 either enables or disables, in every CPU, the C6 C-state controlled
+// by the given setting, depending on whether the provided parameter is true or
+// false, respectively.
+func applyC6(s *setting.MSRBit, enable bool) error {
+	value := setting.Disabled
+	if enable {
+		value = setting.Enabled
 	}
-)
-
-// readMSR reads the MSR of a given CPU at a given offset.
-func readMSR(offset int64, cpu int) (uint64, error) {
-	fname := fmt.Sprintf("/dev/cpu/%d/msr", cpu)
-	f, err := os.OpenFile(fname, os.O_RDONLY, 0666)
-	if err != nil {
-		return 0, err
-	}
-	defer f.Close()
-
-	data := make([]byte, 8)
-	if _, err = f.ReadAt(data, offset); err != nil {
-		return 0, err
-	}
-	return binary.LittleEndian.Uint64(data), nil
-}
-
-// writeMSR writes a value to a specific CPU MSR at a given offset.
-func writeMSR(offset int64, cpu int, value uint64) error {
-	fname := fmt.Sprintf("/dev/cpu/%d/msr", cpu)
-	f, err := os.OpenFile(fname, os.O_WRONLY, 0666)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	data := make([]byte, 8)
-	binary.LittleEndian.PutUint64(data, value)
-	_, err = f.WriteAt(data, offset)
-	return err
+	return s.Apply(value)
 }
 
 // changePackageC6 either enables or disables the C6 package C-state, depending
 // on whether the provided parameter is true or false, respectively.
 func changePackageC6(enable bool) error {
-	// msr[0] is C6 Package.
-	m := msr[0]
-	cpus := runtime.NumCPU()
-	value := m.bit
-	if !enable {
-		value = ^(m.bit)
-	}
-	for c := 0; c < cpus; c++ {
-		if err := writeMSR(m.offset, c, value); err != nil {
-			return err
-		}
-	}
-	return nil
+	return applyC6(packageC6, enable)
+}
+
+// changeCoreC6 either enables or disables the C6 core C-state, depending on
+// whether the provided parameter is true or false, respectively.
+func changeCoreC6(enable bool) error {
+	return applyC6(coreC6, enable)
 }
 
 // changeC6 either enables or disables the C6 (both core and package) C-state,
 // depending on whether the provided parameter is true or false, respectively.
 func changeC6(enable bool) error {
-	cpus := runtime.NumCPU()
-	for _, m := range msr {
-		value := m.bit
-		if !enable {
-			value = ^(m.bit)
-		}
-		for c := 0; c < cpus; c++ {
-			if err := writeMSR(m.offset, c, value); err != nil {
-				return err
-			}
-		}
+	if err := changePackageC6(enable); err != nil {
+		return err
 	}
-	return nil
+	return changeCoreC6(enable)
+}
+
+// c6MSREnabled returns true if the C6 C-state controlled by the given setting
+// is enabled for any processor.
+func c6MSREnabled(s *setting.MSRBit) (bool, error) {
+	status, err := s.Status()
+	if err != nil {
+		return false, err
+	}
+	return status == setting.Enabled, nil
 }
 
 // c6PackageEnabled returns true or false dependending on whether C6 c-state
@@ -112,35 +66,17 @@ func changeC6(enable bool) error {
 // BIOS/AGESA -- seems to disable, when such option is set to "Typical Current
 // Idle".
 func c6PackageEnabled() (bool, error) {
-	// msr[0] is C6 Package.
-	m := msr[0]
-	cpus := runtime.NumCPU()
-	for c := 0; c < cpus; c++ {
-		data, err := readMSR(m.offset, c)
-		if err != nil {
-			return false, err
-		}
-		if data&(m.bit) == m.bit {
-			return true, nil
-		}
-	}
-	return false, nil
+	return c6MSREnabled(packageC6)
 }
 
 // c6Enable returns true or false depending on whether C6 C-state is enabled or
 // disabled, respectively. This considers both core and package. If either of
 // them is enabled for any processor, it returns true.
 func c6Enabled() (bool, error) {
-	cpus := runtime.NumCPU()
-	for c := 0; c < cpus; c++ {
-		for _, m := range msr {
-			data, err := readMSR(m.offset, c)
-			if err != nil {
-				return false, err
-			}
-			if data&(m.bit) == m.bit {
-				return true, nil
-			}
+	for _, s := range []*setting.MSRBit{packageC6, coreC6} {
+		enabled, err := c6MSREnabled(s)
+		if err != nil || enabled {
+			return enabled, err
 		}
 	}
 	return false, nil
@@ -149,31 +85,52 @@ func c6Enabled() (bool, error) {
 // Available returns a boolean indicating whether we have C6 C-state control
 // available or not. We require the `msr' module for it to be available.
 func Available() bool {
-	if _, err := os.Stat("/dev/cpu/0/msr"); err == nil {
-		return true
-	}
-	return false
+	return msr.Available()
 }
 
-// PackageEnable enables C6 C-state (Package).
-func PackageEnable() error {
+// EnablePackageC6 enables C6 C-state (Package) only.
+func EnablePackageC6() error {
 	// Passing true to indicate we want C6 enabled.
 	return changePackageC6(true)
 }
 
-// PackageDisable disables C6 C-state (Package).
-func PackageDisable() error {
+// DisablePackageC6 disables C6 C-state (Package) only.
+func DisablePackageC6() error {
 	// Passing false to indicate we want C6 disabled.
 	return changePackageC6(false)
 }
 
-// Enable enables C6 C-state.
+// EnableCoreC6 enables C6 C-state (Core) only.
+func EnableCoreC6() error {
+	// Passing true to indicate we want C6 enabled.
+	return changeCoreC6(true)
+}
+
+// DisableCoreC6 disables C6 C-state (Core) only.
+func DisableCoreC6() error {
+	// Passing false to indicate we want C6 disabled.
+	return changeCoreC6(false)
+}
+
+// PackageEnable enables C6 C-state (Package). It is the same as
+// EnablePackageC6.
+func PackageEnable() error {
+	return EnablePackageC6()
+}
+
+// PackageDisable disables C6 C-state (Package). It is the same as
+// DisablePackageC6.
+func PackageDisable() error {
+	return DisablePackageC6()
+}
+
+// Enable enables C6 C-state, both core and package.
 func Enable() error {
 	// Passing true to indicate we want C6 enabled.
 	return changeC6(true)
 }
 
-// Disable disables C6 C-state.
+// Disable disables C6 C-state, both core and package.
 func Disable() error {
 	// Passing false to indicate we want C6 disabled.
 	return changeC6(false)
@@ -189,6 +146,11 @@ func PackageEnabled() (bool, error) {
 	return c6PackageEnabled()
 }
 
+// CoreEnabled returns true if C6 C-state (Core) is enabled.
+func CoreEnabled() (bool, error) {
+	return c6MSREnabled(coreC6)
+}
+
 // Disabled returns true if C6 C-state is disabled.
 func Disabled() (bool, error) {
 	enabled, err := c6Enabled()
@@ -197,3 +159,12 @@ func Disabled() (bool, error) {
 	}
 	return !enabled, nil
 }
+
+// PackageDisabled returns true if C6 C-state (Package) is disabled.
+func PackageDisabled() (bool, error) {
+	enabled, err := c6PackageEnabled()
+	if err != nil {
+		return false, err
+	}
+	return !enabled, nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package c6
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/cpulist"
+)
+
+const (
+	cpuDir = "/sys/devices/system/cpu"
+)
+
+var (
+	// ErrNoCPUIdle is returned when the kernel does not expose cpuidle
+	// states, e.g. when booted with cpuidle.off=1.
+	ErrNoCPUIdle = errors.New("cpuidle states unavailable")
+)
+
+// IdleState identifies the cpuidle state used to cross-check C6.
+type IdleState struct {
+	// Index is the index of the state, as in stateN.
+	Index int
+	// Name is the name given by the kernel, e.g. "C2".
+	Name string
+}
+
+// deepestIdleState returns the cpuidle state we take as C6. The kernel does
+// not name it after the hardware C-state: with acpi_idle, the usual driver on
+// Zen, C6 is entered through the deepest state, which is named C2, so we
+// pick the deepest state, unless one is explicitly named C6.
+func deepestIdleState() (IdleState, error) {
+	var deepest IdleState
+	found := false
+	for i := 0; ; i++ {
+		name, err := ioutil.ReadFile(fmt.Sprintf("%s/cpu0/cpuidle/state%d/name", cpuDir, i))
+		if os.IsNotExist(err) {
+			break
+		}
+		if err != nil {
+			return IdleState{}, err
+		}
+		deepest = IdleState{Index: i, Name: strings.TrimSpace(string(name))}
+		found = true
+		if deepest.Name == "C6" {
+			break
+		}
+	}
+	if !found {
+		return IdleState{}, ErrNoCPUIdle
+	}
+	return deepest, nil
+}
+
+// idleStateUsage returns how many times, since boot, every CPU entered the
+// given cpuidle state.
+func idleStateUsage(state IdleState) (uint64, error) {
+	var usage uint64
+	for _, c := range cpulist.CPUs() {
+		value, err := ioutil.ReadFile(fmt.Sprintf("%s/cpu%d/cpuidle/state%d/usage", cpuDir, c, state.Index))
+		if err != nil {
+			return 0, err
+		}
+		u, err := strconv.ParseUint(strings.TrimSpace(string(value)), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		usage += u
+	}
+	return usage, nil
+}
+
+// IdleEntries samples the cpuidle statistics over the given interval and
+// returns how many times, across every CPU, the state we take as C6 was
+// entered. This is independent of the MSRs checked by Enabled, so a nonzero
+// value is runtime evidence that C6 is in use. It returns ErrNoCPUIdle if
+// the kernel does not expose cpuidle states.
+func IdleEntries(interval time.Duration) (IdleState, uint64, error) {
+	state, err := deepestIdleState()
+	if err != nil {
+		return IdleState{}, 0, err
+	}
+	before, err := idleStateUsage(state)
+	if err != nil {
+		return state, 0, err
+	}
+
+	time.Sleep(interval)
+
+	after, err := idleStateUsage(state)
+	if err != nil {
+		return state, 0, err
+	}
+	return state, after - before, nil
+}
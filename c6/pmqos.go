@@ -0,0 +1,143 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package c6
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/cpulist"
+)
+
+const (
+	// cpuDMALatency holds the system-wide CPU latency constraint, set by
+	// applications that keep it open, e.g. audio servers or games.
+	cpuDMALatency = "/dev/cpu_dma_latency"
+	// noDMALatencyConstraint is the value of cpuDMALatency when nobody
+	// constrains the latency.
+	noDMALatencyConstraint = 2000000000
+
+	// NoIdle is the latency, in a LatencyConstraint, meaning no idle state
+	// may be entered at all.
+	NoIdle = -1
+)
+
+// LatencyConstraint is an active PM QoS constraint on the exit latency of the
+// idle states, which keeps states deeper than allowed, e.g. C6, from being
+// entered regardless of their status.
+type LatencyConstraint struct {
+	// Source is where the constraint was found, e.g. "/dev/cpu_dma_latency".
+	Source string
+	// CPUs are the CPUs constrained, or nil for every CPU.
+	CPUs []int
+	// Latency is the exit latency allowed, in µs, or NoIdle.
+	Latency int64
+}
+
+// globalLatencyConstraint returns the system-wide latency constraint, if any.
+// Reading it requires root, so it is skipped otherwise. Opening the device
+// adds a request of our own, but with the default value, so it does not
+// affect the result.
+func globalLatencyConstraint() (*LatencyConstraint, error) {
+	f, err := os.Open(cpuDMALatency)
+	// Without root, only the per-CPU constraints can be checked.
+	if os.IsNotExist(err) || os.IsPermission(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data := make([]byte, 4)
+	if _, err := f.Read(data); err != nil {
+		return nil, err
+	}
+	latency := int64(int32(binary.LittleEndian.Uint32(data)))
+	if latency >= noDMALatencyConstraint {
+		return nil, nil
+	}
+	return &LatencyConstraint{Source: cpuDMALatency, Latency: latency}, nil
+}
+
+// cpuLatencyConstraints returns the per-CPU latency constraints, grouping the
+// CPUs with the same one. A value of 0 means no constraint, and "n/a" means
+// no idle state is allowed.
+func cpuLatencyConstraints() ([]LatencyConstraint, error) {
+	byLatency := map[int64][]int{}
+	for _, c := range cpulist.CPUs() {
+		value, err := ioutil.ReadFile(fmt.Sprintf("%s/cpu%d/power/pm_qos_resume_latency_us", cpuDir, c))
+		if os.IsNotExist(err) {
+			// Older kernels have no per-CPU constraints.
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		v := strings.TrimSpace(string(value))
+		latency := int64(NoIdle)
+		if v != "n/a" {
+			if latency, err = strconv.ParseInt(v, 10, 64); err != nil {
+				return nil, err
+			}
+			if latency == 0 {
+				continue
+			}
+		}
+		byLatency[latency] = append(byLatency[latency], c)
+	}
+
+	var constraints []LatencyConstraint
+	for latency, cpus := range byLatency {
+		constraints = append(constraints, LatencyConstraint{Source: "pm_qos_resume_latency_us", CPUs: cpus, Latency: latency})
+	}
+	sort.Slice(constraints, func(i, j int) bool { return constraints[i].CPUs[0] < constraints[j].CPUs[0] })
+	return constraints, nil
+}
+
+// LatencyConstraints returns the active PM QoS constraints on the idle state
+// exit latency, either system-wide or per CPU.
+func LatencyConstraints() ([]LatencyConstraint, error) {
+	constraints, err := cpuLatencyConstraints()
+	if err != nil {
+		return nil, err
+	}
+	global, err := globalLatencyConstraint()
+	if err != nil {
+		return constraints, err
+	}
+	if global != nil {
+		constraints = append([]LatencyConstraint{*global}, constraints...)
+	}
+	return constraints, nil
+}
+
+// IdleExitLatency returns the exit latency, in µs, of the cpuidle state we
+// take as C6. Latency constraints below it keep C6 from being entered.
+func IdleExitLatency() (int64, error) {
+	state, err := deepestIdleState()
+	if err != nil {
+		return 0, err
+	}
+	value, err := ioutil.ReadFile(fmt.Sprintf("%s/cpu0/cpuidle/state%d/latency", cpuDir, state.Index))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(value)), 10, 64)
+}
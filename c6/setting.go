@@ -0,0 +1,106 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package c6
+
+import (
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/msr"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/setting"
+)
+
+// idleParams are the kernel command line parameters that keep C6 from being
+// entered, whatever its MSRs say, e.g. idle=poll.
+var idleParams = []string{"idle", "processor.max_cstate", "cpuidle.off"}
+
+var (
+	// packageC6 and coreC6 are the settings for C6 package and core, a
+	// C-state (idle power saving state). Magic numbers for the MSRs
+	// obtained from ZenStates-Linux project available at
+	// https://github.com/r4m0n/ZenStates-Linux. C6 is reported as enabled
+	// if any CPU has it enabled, as that is enough to be affected by it.
+	packageC6 = &setting.MSRBit{
+		Toggle: setting.Toggle{
+			Key:           "c6package",
+			Label:         "C6 C-state (Package)",
+			ConfirmValues: []string{setting.Disabled},
+			BootDefault:   setting.Enabled,
+			Touches:       "MSR 0xC0010292 bit 32, on every CPU",
+			KernelParams:  idleParams,
+			Order:         setting.OrderC6,
+		},
+		Register: 0xC0010292,
+		Bits:     1 << 32,
+		PerCore:  true,
+		SetMeans: setting.Enabled,
+		Mixed:    setting.Enabled,
+	}
+	coreC6 = &setting.MSRBit{
+		Toggle: setting.Toggle{
+			Key:           "c6core",
+			Label:         "C6 C-state (Core)",
+			ConfirmValues: []string{setting.Disabled},
+			BootDefault:   setting.Enabled,
+			Touches:       "MSR 0xC0010296 bits 22, 14 and 6, on every CPU",
+			KernelParams:  idleParams,
+			Order:         setting.OrderC6,
+		},
+		Register: 0xC0010296,
+		Bits:     (1 << 22) | (1 << 14) | (1 << 6),
+		PerCore:  true,
+		SetMeans: setting.Enabled,
+		Mixed:    setting.Enabled,
+	}
+)
+
+func init() {
+	// C6 must come before the individual package and core controls, and the
+	// Power Supply Idle Control workaround, since changing C6 changes both
+	// package and core, and the workaround controls C6 package.
+	setting.Register(&setting.Toggle{
+		Key:          "c6",
+		Label:        "C6 C-state",
+		Availability: availability,
+		Enable:       Enable,
+		Disable:      Disable,
+		IsEnabled:    Enabled,
+		// Disabling C6 on the wrong machine may cause instability.
+		ConfirmValues: []string{setting.Disabled},
+		BootDefault:   setting.Enabled,
+		Touches:       "MSR 0xC0010292 bit 32 and MSR 0xC0010296 bits 22, 14 and 6, on every CPU",
+		KernelParams:  idleParams,
+		Order:         setting.OrderC6,
+	})
+	setting.Register(packageC6)
+	setting.Register(coreC6)
+	// The workaround disables C6 package, so enabling it means disabling C6
+	// package and vice versa.
+	setting.Register(&setting.Toggle{
+		Key:          "psicworkaround",
+		Label:        "Power Supply Idle Control workaround",
+		Availability: availability,
+		Enable:       PackageDisable,
+		Disable:      PackageEnable,
+		IsEnabled:    PackageDisabled,
+		// Enabling the workaround disables C6 package.
+		ConfirmValues: []string{setting.Enabled},
+		BootDefault:   setting.Disabled,
+		Touches:       "MSR 0xC0010292 bit 32, on every CPU",
+		Order:         setting.OrderC6,
+	})
+}
+
+// availability explains why C6 C-state control is unavailable, if that is the
+// case.
+func availability() error {
+	return msr.Check()
+}
@@ -0,0 +1,154 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/setting"
+)
+
+// settingComparison has the current value of a setting and, if a
+// configuration was provided, the value it asks for.
+type settingComparison struct {
+	setting setting.Setting
+	// unavailable explains why the setting cannot be managed, if that is the
+	// case.
+	unavailable error
+	// current is the current value, unless err is set.
+	current string
+	err     error
+	// desired is the normalized value from the configuration, if configured.
+	desired    string
+	configured bool
+}
+
+// mismatch returns whether the setting is known to differ from the value the
+// configuration asks for.
+func (c *settingComparison) mismatch() bool {
+	return c.configured && c.unavailable == nil && c.err == nil && c.current != c.desired
+}
+
+// compareSettings reads, concurrently, the current value of every available
+// setting, comparing it to the one in cfg, which may be nil. Unavailable
+// settings are included only if cfg mentions them.
+func compareSettings(cfg *configuration) []*settingComparison {
+	var comparisons []*settingComparison
+	settings := setting.All()
+	if cfg != nil {
+		settings = append(settings, cfg.extraSettings()...)
+	}
+	for _, s := range settings {
+		c := &settingComparison{setting: s, unavailable: s.Available()}
+		if cfg != nil {
+			var value string
+			value, c.configured = cfg.settings.value(s.Name())
+			c.desired = setting.Normalize(s, value)
+		}
+		if c.unavailable != nil && !c.configured {
+			continue
+		}
+		comparisons = append(comparisons, c)
+	}
+
+	type result struct {
+		current string
+		err     error
+	}
+	results := make([]result, len(comparisons))
+	sources := make([]string, len(comparisons))
+	fns := make([]func(), len(comparisons))
+	for i, c := range comparisons {
+		i, c := i, c
+		sources[i] = "setting " + c.setting.Name()
+		fns[i] = func() {
+			if c.unavailable == nil {
+				results[i].current, results[i].err = c.setting.Status()
+			}
+		}
+	}
+	for i, err := range runConcurrently(sources, fns, statusTimeout) {
+		if err != nil {
+			comparisons[i].err = err
+			continue
+		}
+		comparisons[i].current, comparisons[i].err = results[i].current, results[i].err
+	}
+	return comparisons
+}
+
+// displayValue returns value the way it is displayed for the given setting:
+// in upper case, e.g. ENABLED, unless the values of the setting are free-form,
+// e.g. the FID, DID and VID of a P-state, where case may matter for reading.
+func displayValue(s setting.Setting, value string) string {
+	if _, ok := s.(setting.Validator); ok {
+		return value
+	}
+	return strings.ToUpper(value)
+}
+
+// settingsTable renders the comparisons as a table with the current value of
+// each setting and, if any of them is configured, the configured value and
+// whether they match.
+func settingsTable(comparisons []*settingComparison) []string {
+	withConfig := false
+	for _, c := range comparisons {
+		withConfig = withConfig || c.configured
+	}
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	header := "SETTING\tSTATUS"
+	if withConfig {
+		header += "\tCONFIGURED\tMATCH"
+	}
+	// Whether the settings persist, and how they are managed, is shown in
+	// verbose mode only, as it does not change.
+	if verbose {
+		header += "\tPERSISTENCE\tMECHANISM"
+	}
+	fmt.Fprintln(w, header)
+	for _, c := range comparisons {
+		status := displayValue(c.setting, c.current)
+		switch {
+		case c.unavailable != nil:
+			status = fmt.Sprintf("unavailable - %v", c.unavailable)
+		case c.err != nil:
+			status = fmt.Sprintf("error: %v", c.err)
+		}
+		row := capitalize(c.setting.Description()) + "\t" + status
+		if withConfig {
+			configured, match := "-", ""
+			if c.configured {
+				configured = displayValue(c.setting, c.desired)
+				switch {
+				case c.mismatch():
+					match = "NO"
+				case c.unavailable == nil && c.err == nil:
+					match = "yes"
+				}
+			}
+			row += "\t" + configured + "\t" + match
+		}
+		if verbose {
+			row += "\t" + setting.Persistence(c.setting) + "\t" + setting.Mechanism(c.setting)
+		}
+		fmt.Fprintln(w, row)
+	}
+	w.Flush()
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+}
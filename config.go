@@ -0,0 +1,455 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/cpufreq"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/cpulist"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/kernel"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/setting"
+	"gopkg.in/yaml.v3"
+)
+
+// rsSettings contains the contents of a config file. Each registered setting,
+// such as C6 C-state, processor boosting, address space layout randomization
+// (ASLR) or the power supply idle control workaround (PSIC Workaround), is
+// looked up by its name, e.g. `c6', and accepts the values described by the
+// setting itself; for the ones that can be enabled or disabled, `enable' and
+// `disable'.
+type rsSettings map[string]interface{}
+
+// value returns the value provided for the named setting, and whether there
+// was one at all.
+func (r rsSettings) value(name string) (string, bool) {
+	v, ok := r[name]
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprint(v), true
+}
+
+const (
+	// stdinConfig is the config file name standing for the standard input.
+	stdinConfig = "-"
+
+	formatTOML = "toml"
+	formatYAML = "yaml"
+)
+
+var (
+	// configFormat, if not empty, is the format of every config file,
+	// instead of the one implied by its extension.
+	configFormat = ""
+)
+
+// parseConfigFormat checks format is one of the supported config formats.
+func parseConfigFormat(format string) (string, error) {
+	switch f := strings.ToLower(format); f {
+	case formatTOML, formatYAML:
+		return f, nil
+	}
+	return "", fmt.Errorf("invalid config format %q; expected %s or %s", format, formatTOML, formatYAML)
+}
+
+// configFormatFor returns the format of the named config file: configFormat,
+// if set, or yaml for the .yaml and .yml extensions, and toml otherwise, e.g.
+// for the standard input. For a URL, the extension is that of its path, so
+// that e.g. a query string is ignored.
+func configFormatFor(configFile string) string {
+	if configFormat != "" {
+		return configFormat
+	}
+	name := configFile
+	if isURL(configFile) {
+		if u, err := url.Parse(configFile); err == nil {
+			name = u.Path
+		}
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml":
+		return formatYAML
+	}
+	return formatTOML
+}
+
+// readConfigurationFile reads and parses the provided configuration file, or
+// the standard input if it is stdinConfig. Whether the file can be trusted to
+// run hooks as root is recorded in c, from the file it was read from; see
+// checkOwner.
+func (c *configuration) readConfigurationFile(configFile string) (rsSettings, error) {
+	var buf []byte
+	var err error
+	if configFile == stdinConfig {
+		buf, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		var f *os.File
+		if f, err = os.Open(configFile); err == nil {
+			c.untrusted[configFile] = checkOwner(f, configFile)
+			buf, err = ioutil.ReadAll(f)
+			f.Close()
+		}
+	}
+	if err != nil {
+		return rsSettings{}, fmt.Errorf("unable to read contents of config file %q: %v", configFile, err)
+	}
+	return parseConfiguration(configFile, buf)
+}
+
+// parseConfiguration parses the contents of the named configuration file, in
+// the format given by configFormatFor.
+func parseConfiguration(configFile string, buf []byte) (rsSettings, error) {
+	// A plain map, so that decoders use it for nested tables as well.
+	settings := map[string]interface{}{}
+	var err error
+	switch configFormatFor(configFile) {
+	case formatYAML:
+		err = yaml.Unmarshal(buf, &settings)
+	default:
+		_, err = toml.Decode(string(buf), &settings)
+	}
+	if err != nil {
+		return rsSettings{}, fmt.Errorf("problem parsing config file %q: %v", configFile, err)
+	}
+
+	// Keys are matched against setting names regardless of case. Tables,
+	// e.g. [self], are flattened into dotted keys, such as `self.nice'.
+	flattened := rsSettings{}
+	flattened.flatten("", settings)
+	return flattened, nil
+}
+
+// flatten adds the given settings to r, with lowercase keys prefixed by
+// prefix, flattening nested tables into dotted keys. Keys in the [extra]
+// table are sysfs paths, so their case is kept.
+func (r rsSettings) flatten(prefix string, settings map[string]interface{}) {
+	for k, v := range settings {
+		key := prefix + strings.ToLower(k)
+		if prefix == extraPrefix {
+			key = prefix + k
+		}
+		if table, ok := v.(map[string]interface{}); ok {
+			r.flatten(key+".", table)
+			continue
+		}
+		r[key] = v
+	}
+}
+
+// configuration is the result of reading and merging config files.
+type configuration struct {
+	// settings has the merged settings.
+	settings rsSettings
+	// files has the config files read, in order.
+	files []string
+	// sources records where the final value of each key came from, e.g.
+	// "file:base.toml".
+	sources map[string]string
+	// warnings has the problems found while reading the config files that
+	// did not prevent reading them, e.g. falling back to a cached copy.
+	warnings []string
+	// untrusted records, for each config file read from the file system,
+	// why it cannot be trusted to run hooks as root, or nil if it can.
+	untrusted map[string]error
+}
+
+// newConfiguration returns an empty configuration.
+func newConfiguration() *configuration {
+	return &configuration{settings: rsSettings{}, sources: map[string]string{}, untrusted: map[string]error{}}
+}
+
+// addFile merges the settings read from the given file into c, overriding
+// existing ones.
+func (c *configuration) addFile(file string, settings rsSettings) {
+	for k, v := range settings {
+		c.settings[k] = v
+		c.sources[k] = "file:" + file
+	}
+	c.files = append(c.files, file)
+}
+
+// configDirPatterns match the config files read from a config directory.
+var configDirPatterns = []string{"*.toml", "*.yaml", "*.yml"}
+
+// readConfigurationDir reads every config file (*.toml, *.yaml and *.yml) in
+// dir, in lexical order, whatever their format, and merges them into c, so
+// later files override earlier ones.
+func (c *configuration) readConfigurationDir(dir string) error {
+	var files []string
+	for _, pattern := range configDirPatterns {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return fmt.Errorf("unable to list config files in %q: %v", dir, err)
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+
+	for _, f := range files {
+		settings, err := c.readConfigurationFile(f)
+		if err != nil {
+			return err
+		}
+		c.addFile(f, settings)
+	}
+	return nil
+}
+
+// loadConfiguration reads the config file, which may also be an HTTP(S) URL,
+// and the config files in configDir, either of which may be empty. The config
+// file comes first, so the files in configDir override it.
+func loadConfiguration(configFile, configDir string) (*configuration, error) {
+	c := newConfiguration()
+
+	if isURL(configFile) {
+		settings, err := c.readRemoteConfiguration(configFile)
+		if err != nil {
+			return nil, err
+		}
+		c.addFile(configFile, settings)
+	} else if configFile != "" {
+		settings, err := c.readConfigurationFile(configFile)
+		if err != nil {
+			return nil, err
+		}
+		c.addFile(configFile, settings)
+	}
+
+	if configDir != "" {
+		if err := c.readConfigurationDir(configDir); err != nil {
+			return nil, err
+		}
+	}
+
+	// Without the running kernel version, min_kernel cannot be checked,
+	// which is not worth skipping every setting for.
+	if _, ok := c.settings.value(minKernelKey); ok {
+		if _, err := kernel.Running(); err != nil {
+			c.warnings = append(c.warnings, fmt.Sprintf("unable to determine the kernel version, so min_kernel is not checked: %v", err))
+		}
+	}
+	return c, nil
+}
+
+const (
+	// strictKey is the config key that, when true, makes problems in the
+	// configuration fatal.
+	strictKey = "strict"
+
+	// minKernelKey is the config key with the oldest kernel version the
+	// settings in the configuration are meant for.
+	minKernelKey = "min_kernel"
+)
+
+// optionKeys are the config keys that are not settings, but options about how
+// ryzen-stabilizator itself runs.
+var optionKeys = []string{strictKey, minKernelKey, postApplyKey, selfNiceKey, selfAffinityKey, selfUmaskKey, onDriftKey, onDriftURLKey}
+
+// strict returns whether the configuration asks for problems in it to be
+// fatal.
+func (c *configuration) strict() bool {
+	v, ok := c.settings.value(strictKey)
+	if !ok {
+		return false
+	}
+	strict, err := strconv.ParseBool(v)
+	return err == nil && strict
+}
+
+// problems returns the problems found in the configuration, i.e. unknown keys,
+// which are usually typos, and values not accepted by their settings, in
+// lexical order of the keys.
+func (c *configuration) problems() []string {
+	problems := c.structuralProblems()
+	for _, k := range c.sortedKeys() {
+		source := strings.TrimPrefix(c.sources[k], "file:")
+		s := c.settingFor(k)
+		if s == nil {
+			continue
+		}
+		v, _ := c.settings.value(k)
+		if err := setting.Validate(s, v); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid value %q for %q in %q: %v", v, k, source, err))
+		}
+	}
+	if conflict := c.governorConflict(); conflict != "" {
+		problems = append(problems, fmt.Sprintf("conflicting values: %s, so governor is not applied", conflict))
+	}
+	if v, ok := c.settings.value(minKernelKey); ok {
+		if _, err := kernel.ParseVersion(v); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid value %q for %q: %v", v, minKernelKey, err))
+		}
+	}
+	if v, ok := c.settings.value(strictKey); ok {
+		if _, err := strconv.ParseBool(v); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid value %q for %q; expected true or false", v, strictKey))
+		}
+	}
+	if v, ok := c.settings.value(selfNiceKey); ok {
+		if _, err := parseNice(v); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid value %q for %q; expected -20 to 19", v, selfNiceKey))
+		}
+	}
+	if v, ok := c.settings.value(selfAffinityKey); ok {
+		if _, err := cpulist.Parse(v); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid value %q for %q: %v", v, selfAffinityKey, err))
+		}
+	}
+	if v, ok := c.settings.value(onDriftURLKey); ok && !isURL(v) {
+		problems = append(problems, fmt.Sprintf("invalid value %q for %q; expected an HTTP(S) URL", v, onDriftURLKey))
+	}
+	if v, ok := c.settings.value(selfUmaskKey); ok {
+		if _, err := parseUmask(v); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid value %q for %q; expected an octal value up to 0777", v, selfUmaskKey))
+		}
+	}
+	return problems
+}
+
+// governorConflict returns why the governor setting of c conflicts with its
+// pin_frequency setting, or "" if it does not: pinning the frequency sets the
+// performance governor, so another governor would be undone in every apply,
+// and be reported as drift by the daemon every time.
+func (c *configuration) governorConflict() string {
+	governor, ok := c.settings.value("governor")
+	pin, pinned := c.settings.value("pin_frequency")
+	governorSetting, pinSetting := setting.Lookup("governor"), setting.Lookup("pin_frequency")
+	if !ok || !pinned || governorSetting == nil || pinSetting == nil {
+		return ""
+	}
+	if setting.Normalize(pinSetting, pin) == cpufreq.Unpinned || setting.Normalize(governorSetting, governor) == cpufreq.Performance {
+		return ""
+	}
+	return fmt.Sprintf("pin_frequency %q sets the %s governor, which governor %q would undo", pin, cpufreq.Performance, governor)
+}
+
+// structuralProblems returns the problems in the configuration that can be
+// found without looking at the machine, i.e. the keys that are neither a
+// setting nor an option. Values are not checked, as some settings need to ask
+// the hardware or the kernel which values they accept.
+func (c *configuration) structuralProblems() []string {
+	var problems []string
+	for _, k := range c.sortedKeys() {
+		if c.settingFor(k) != nil {
+			continue
+		}
+		known := false
+		for _, o := range optionKeys {
+			known = known || o == k
+		}
+		if !known {
+			problems = append(problems, fmt.Sprintf("unknown key %q in %q", k, strings.TrimPrefix(c.sources[k], "file:")))
+		}
+	}
+	return problems
+}
+
+// sortedKeys returns the keys in the configuration, sorted.
+func (c *configuration) sortedKeys() []string {
+	keys := make([]string, 0, len(c.settings))
+	for k := range c.settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// settingFor returns the setting the config key k refers to, or nil if it is
+// not a setting.
+func (c *configuration) settingFor(k string) setting.Setting {
+	if strings.HasPrefix(k, extraPrefix) {
+		return extraSetting(strings.TrimPrefix(k, extraPrefix))
+	}
+	return setting.Lookup(k)
+}
+
+// settingFilter selects which settings from the configuration get applied.
+type settingFilter struct {
+	only map[string]bool
+	skip map[string]bool
+}
+
+// parseSettingNames parses a comma-separated list of setting names, checking
+// each of them against the registered settings.
+func parseSettingNames(list string) (map[string]bool, error) {
+	names := map[string]bool{}
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if setting.Lookup(name) == nil {
+			return nil, fmt.Errorf("unknown setting %q", name)
+		}
+		names[name] = true
+	}
+	return names, nil
+}
+
+// newSettingFilter returns a filter allowing only the settings listed in only,
+// if it is not empty, except for the ones listed in skip.
+func newSettingFilter(only, skip string) (*settingFilter, error) {
+	f := &settingFilter{}
+	var err error
+	if only != "" {
+		if f.only, err = parseSettingNames(only); err != nil {
+			return nil, fmt.Errorf("invalid -only: %v", err)
+		}
+	}
+	if f.skip, err = parseSettingNames(skip); err != nil {
+		return nil, fmt.Errorf("invalid -skip: %v", err)
+	}
+	return f, nil
+}
+
+// allows returns whether the named setting should be applied.
+func (f *settingFilter) allows(name string) bool {
+	if f.only != nil && !f.only[name] {
+		return false
+	}
+	return !f.skip[name]
+}
+
+// kernelTooOld returns a description of why the running kernel is too old for
+// the configuration, according to its min_kernel key, or an empty string if
+// it is not, or if its version is unknown.
+func (c *configuration) kernelTooOld() string {
+	v, ok := c.settings.value(minKernelKey)
+	if !ok {
+		return ""
+	}
+	min, err := kernel.ParseVersion(v)
+	if err != nil {
+		// Already reported as a problem in the configuration.
+		return ""
+	}
+	running, err := kernel.Running()
+	if err != nil {
+		// Already warned about when loading the configuration.
+		return ""
+	}
+	if running.Compare(min) < 0 {
+		return fmt.Sprintf("kernel %s is older than min_kernel %s", running, min)
+	}
+	return ""
+}
@@ -0,0 +1,38 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "testing"
+
+func TestConfigFormatFor(t *testing.T) {
+	tests := []struct {
+		configFile string
+		want       string
+	}{
+		{"/etc/ryzen-stabilizator/settings.toml", formatTOML},
+		{"/etc/ryzen-stabilizator/settings.yaml", formatYAML},
+		{"/etc/ryzen-stabilizator/settings.YML", formatYAML},
+		{stdinConfig, formatTOML},
+		{"https://example.com/settings.yaml", formatYAML},
+		{"https://example.com/settings.yaml?token=abc", formatYAML},
+		{"https://example.com/settings.yml#top", formatYAML},
+		{"https://example.com/settings?format=.yaml", formatTOML},
+		{"http://example.com/", formatTOML},
+	}
+	for _, tt := range tests {
+		if got := configFormatFor(tt.configFile); got != tt.want {
+			t.Errorf("configFormatFor(%q) = %q, want %q", tt.configFile, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+)
+
+// configCheck validates the config file and the config files in configDir,
+// without applying anything, and reports every problem found: keys that are
+// not known, values a setting does not accept, e.g. out of range, and invalid
+// options. It returns the exit code, 0 if the configuration is valid, 1
+// otherwise. With parseOnly, only decoding the files and the keys in them are
+// checked, which is fast and never touches the hardware, e.g. for pre-commit
+// hooks and editors.
+func configCheck(configFile, configDir string, parseOnly bool) int {
+	if configFile == "" && configDir == "" {
+		fmt.Println("Error: -config-check and -config-test-only-parse require a config file.")
+		return 1
+	}
+	cfg, err := loadConfiguration(configFile, configDir)
+	if err != nil {
+		fmt.Printf("Error: %v.\n", err)
+		return 1
+	}
+	for _, w := range cfg.warnings {
+		fmt.Printf("Warning: %s.\n", w)
+	}
+
+	var problems []string
+	if parseOnly {
+		problems = cfg.structuralProblems()
+	} else {
+		problems = cfg.problems()
+	}
+	for _, p := range problems {
+		fmt.Printf("Error: %s.\n", p)
+	}
+	switch len(problems) {
+	case 0:
+		if parseOnly {
+			fmt.Println("Configuration parses.")
+		} else {
+			fmt.Println("Configuration is valid.")
+		}
+		return 0
+	case 1:
+		fmt.Println("1 problem found.")
+	default:
+		fmt.Printf("%d problems found.\n", len(problems))
+	}
+	return 1
+}
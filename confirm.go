@@ -0,0 +1,104 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/cpulist"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/setting"
+)
+
+var (
+	// assumeYes indicates risky changes should be applied without asking for
+	// confirmation.
+	assumeYes = false
+
+	// force indicates changes that may keep the machine from booting, such as
+	// P-states, should be applied without asking. Unlike assumeYes, without
+	// it such changes are not applied unless confirmed interactively.
+	force = false
+
+	// stdinReader reads the answers to the confirmation prompts. It is
+	// shared by every prompt, so that the answers buffered past the first
+	// line, e.g. when piped, are not lost.
+	stdinReader = bufio.NewReader(os.Stdin)
+)
+
+// interactive returns whether stdout is a terminal, i.e. whether a human is
+// likely to be watching.
+func interactive() bool {
+	info, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// confirmationPrompt returns the question asked before applying value to s.
+func confirmationPrompt(s setting.Setting, value string) string {
+	var action string
+	switch setting.Normalize(s, value) {
+	case setting.Enabled:
+		action = fmt.Sprintf("enable %s on %d CPUs", s.Description(), cpulist.Count())
+	case setting.Disabled:
+		action = fmt.Sprintf("disable %s on %d CPUs", s.Description(), cpulist.Count())
+	default:
+		action = fmt.Sprintf("set %s to %q", s.Description(), value)
+	}
+	return fmt.Sprintf("About to %s, continue? [y/N] ", action)
+}
+
+// confirm asks the user whether value should be applied to s, if s considers
+// it a risky change. Automation is not impeded: we only ask if stdout is a
+// terminal and -yes was not given. Changes for which s requires an explicit
+// confirmation, though, are only applied with -force, or if the user is asked
+// and agrees.
+func confirm(s setting.Setting, value string) bool {
+	if setting.NeedsForce(s, value) {
+		if force {
+			return true
+		}
+		if !interactive() {
+			return false
+		}
+	} else {
+		c, ok := s.(setting.Confirmable)
+		if !ok || !c.NeedsConfirmation(value) || assumeYes || !interactive() {
+			return true
+		}
+	}
+
+	// The prompt goes to stderr so that it does not end up mixed with JSON
+	// output.
+	fmt.Fprint(os.Stderr, confirmationPrompt(s, value))
+	answer, err := stdinReader.ReadString('\n')
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// forceRequired returns whether value is not applied to s only because it
+// requires -force, as nobody can be asked.
+func forceRequired(s setting.Setting, value string) bool {
+	return setting.NeedsForce(s, value) && !force && !interactive()
+}
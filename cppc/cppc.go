@@ -0,0 +1,100 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cppc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/cpulist"
+)
+
+const (
+	cpuDir = "/sys/devices/system/cpu"
+)
+
+// Performance limits of a CPU exposed by CPPC, as named in sysfs. They are
+// abstract performance levels, not frequencies; the nominal one is the
+// highest sustainable without boosting.
+const (
+	Highest         = "highest_perf"
+	Guaranteed      = "guaranteed_perf"
+	Nominal         = "nominal_perf"
+	LowestNonlinear = "lowest_nonlinear_perf"
+	Lowest          = "lowest_perf"
+)
+
+// Limits are the performance limits, from the highest to the lowest. Not
+// every kernel or firmware exposes all of them, e.g. the guaranteed one.
+var Limits = []string{Highest, Guaranteed, Nominal, LowestNonlinear, Lowest}
+
+// Available returns a boolean indicating whether the kernel exposes ACPI CPPC
+// (Collaborative Processor Performance Control) information.
+func Available() bool {
+	if _, err := os.Stat(cpuDir + "/cpu0/acpi_cppc"); err == nil {
+		return true
+	}
+	return false
+}
+
+// readPerf reads one of the CPPC performance values of a CPU.
+func readPerf(cpu int, name string) (uint64, error) {
+	value, err := ioutil.ReadFile(fmt.Sprintf("%s/cpu%d/acpi_cppc/%s", cpuDir, cpu, name))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(value)), 10, 64)
+}
+
+// Perf returns the given performance limit of the given CPU, e.g. Nominal.
+func Perf(cpu int, limit string) (uint64, error) {
+	return readPerf(cpu, limit)
+}
+
+// NominalFrequency returns the frequency, in MHz, matching the nominal
+// performance of the given CPU, i.e. its base frequency.
+func NominalFrequency(cpu int) (uint64, error) {
+	return readPerf(cpu, "nominal_freq")
+}
+
+// HighestPerf returns the highest performance the given CPU can reach, as
+// ranked by the firmware. On Zen processors, the best cores (the ones able to
+// boost the highest) have the highest value.
+func HighestPerf(cpu int) (uint64, error) {
+	return readPerf(cpu, "highest_perf")
+}
+
+// PreferredCores returns the CPUs with the highest CPPC highest performance,
+// i.e. the preferred cores.
+func PreferredCores() ([]int, error) {
+	var preferred []int
+	var best uint64
+	for _, c := range cpulist.CPUs() {
+		perf, err := HighestPerf(c)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case perf > best:
+			best = perf
+			preferred = []int{c}
+		case perf == best:
+			preferred = append(preferred, c)
+		}
+	}
+	return preferred, nil
+}
@@ -0,0 +1,295 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cpufreq
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/cpulist"
+)
+
+const (
+	// Performance is the governor that keeps the frequency as high as its
+	// limits allow.
+	Performance = "performance"
+	// Mixed is returned by CommonGovernor when the CPUs do not all have the
+	// same governor.
+	Mixed = "mixed"
+)
+
+// policyFile returns the path of a cpufreq file of the given CPU.
+func policyFile(cpu int, name string) string {
+	return fmt.Sprintf("/sys/devices/system/cpu/cpu%d/cpufreq/%s", cpu, name)
+}
+
+// readFile returns the contents of a cpufreq file of the given CPU, trimmed.
+func readFile(cpu int, name string) (string, error) {
+	value, err := ioutil.ReadFile(policyFile(cpu, name))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(value)), nil
+}
+
+// writeFile writes value to a cpufreq file of the given CPU.
+func writeFile(cpu int, name, value string) error {
+	return ioutil.WriteFile(policyFile(cpu, name), []byte(value), 0644)
+}
+
+// readMHz returns a frequency from a cpufreq file of the given CPU, which has
+// it in kHz, in MHz.
+func readMHz(cpu int, name string) (int, error) {
+	value, err := readFile(cpu, name)
+	if err != nil {
+		return 0, err
+	}
+	khz, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	return khz / 1000, nil
+}
+
+// writeMHz writes a frequency, in MHz, to a cpufreq file of the given CPU.
+func writeMHz(cpu int, name string, mhz int) error {
+	return writeFile(cpu, name, strconv.Itoa(mhz*1000))
+}
+
+// Available returns a boolean indicating whether cpufreq is available, which
+// is not the case when AMD Cool'n'Quiet is disabled, for instance.
+func Available() bool {
+	if _, err := os.Stat(policyFile(0, "scaling_governor")); err == nil {
+		return true
+	}
+	return false
+}
+
+// Governors returns the governors available for the given CPU.
+func Governors(cpu int) ([]string, error) {
+	value, err := readFile(cpu, "scaling_available_governors")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(value), nil
+}
+
+// Governor returns the current governor of the given CPU.
+func Governor(cpu int) (string, error) {
+	return readFile(cpu, "scaling_governor")
+}
+
+// HardwareLimits returns the lowest and highest frequencies, in MHz, that the
+// given CPU supports.
+func HardwareLimits(cpu int) (min, max int, err error) {
+	if min, err = readMHz(cpu, "cpuinfo_min_freq"); err != nil {
+		return 0, 0, err
+	}
+	if max, err = readMHz(cpu, "cpuinfo_max_freq"); err != nil {
+		return 0, 0, err
+	}
+	return min, max, nil
+}
+
+// AvailableFrequencies returns the frequencies, in MHz, the given CPU can be
+// set to, for drivers with a discrete set of them, e.g. acpi-cpufreq, in
+// which only the P-states can be selected. It returns nil for drivers that
+// accept any frequency within the limits of the hardware, e.g. amd_pstate.
+func AvailableFrequencies(cpu int) ([]int, error) {
+	value, err := readFile(cpu, "scaling_available_frequencies")
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var frequencies []int
+	for _, f := range strings.Fields(value) {
+		khz, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid available frequency %q", f)
+		}
+		frequencies = append(frequencies, khz/1000)
+	}
+	return frequencies, nil
+}
+
+// Limits returns the current lowest and highest frequencies, in MHz, the
+// governor of the given CPU may select.
+func Limits(cpu int) (min, max int, err error) {
+	if min, err = readMHz(cpu, "scaling_min_freq"); err != nil {
+		return 0, 0, err
+	}
+	if max, err = readMHz(cpu, "scaling_max_freq"); err != nil {
+		return 0, 0, err
+	}
+	return min, max, nil
+}
+
+// SetLimits sets the lowest and highest frequencies, in MHz, the governor of
+// the given CPU may select. They are written in the order that keeps the
+// lowest one below the highest one at all times, as the kernel requires.
+func SetLimits(cpu, min, max int) error {
+	current, _, err := Limits(cpu)
+	if err != nil {
+		return err
+	}
+	if max < current {
+		if err := writeMHz(cpu, "scaling_min_freq", min); err != nil {
+			return err
+		}
+		return writeMHz(cpu, "scaling_max_freq", max)
+	}
+	if err := writeMHz(cpu, "scaling_max_freq", max); err != nil {
+		return err
+	}
+	return writeMHz(cpu, "scaling_min_freq", min)
+}
+
+// policy is the governor and frequency limits of a CPU.
+type policy struct {
+	governor string
+	min, max int
+}
+
+// readPolicy returns the current governor and frequency limits of a CPU.
+func readPolicy(cpu int) (policy, error) {
+	governor, err := Governor(cpu)
+	if err != nil {
+		return policy{}, err
+	}
+	min, max, err := Limits(cpu)
+	if err != nil {
+		return policy{}, err
+	}
+	return policy{governor: governor, min: min, max: max}, nil
+}
+
+// restore sets the governor and frequency limits of a CPU back to p.
+func (p policy) restore(cpu int) error {
+	if err := writeFile(cpu, "scaling_governor", p.governor); err != nil {
+		return err
+	}
+	return SetLimits(cpu, p.min, p.max)
+}
+
+// pinCPU sets the performance governor and both frequency limits of a CPU to
+// mhz, and checks the limits read back as written, as the kernel adjusts a
+// frequency the driver cannot select to one it can.
+func pinCPU(cpu, mhz int) error {
+	if err := writeFile(cpu, "scaling_governor", Performance); err != nil {
+		return fmt.Errorf("unable to set the %s governor: %v", Performance, err)
+	}
+	if err := SetLimits(cpu, mhz, mhz); err != nil {
+		return fmt.Errorf("unable to set the frequency limits: %v", err)
+	}
+	min, max, err := Limits(cpu)
+	if err != nil {
+		return fmt.Errorf("unable to read the frequency limits back: %v", err)
+	}
+	if min != mhz || max != mhz {
+		return fmt.Errorf("frequency limits read back as %d-%d MHz instead of %d MHz, which the driver may not support", min, max, mhz)
+	}
+	return nil
+}
+
+// Pin sets the performance governor and both frequency limits to mhz on every
+// CPU, so that the frequency no longer scales. If a CPU fails, the governor
+// and limits of the CPUs changed so far are restored, so that they are not
+// left pinned only in part.
+func Pin(mhz int) error {
+	var changed []int
+	var previous []policy
+	for _, c := range cpulist.CPUs() {
+		p, err := readPolicy(c)
+		if err != nil {
+			return fmt.Errorf("CPU %d: unable to obtain the governor and frequency limits: %v", c, err)
+		}
+		changed, previous = append(changed, c), append(previous, p)
+		if err := pinCPU(c, mhz); err != nil {
+			for i, done := range changed {
+				previous[i].restore(done)
+			}
+			return fmt.Errorf("CPU %d: %v; restored the previous governor and frequency limits", c, err)
+		}
+	}
+	return nil
+}
+
+// Unpin sets the frequency limits of every CPU back to the ones of the
+// hardware, so that the governor, left as is, may select any frequency.
+func Unpin() error {
+	for _, c := range cpulist.CPUs() {
+		min, max, err := HardwareLimits(c)
+		if err != nil {
+			return fmt.Errorf("CPU %d: unable to obtain the frequency limits: %v", c, err)
+		}
+		if err := SetLimits(c, min, max); err != nil {
+			return fmt.Errorf("CPU %d: unable to set the frequency limits: %v", c, err)
+		}
+	}
+	return nil
+}
+
+// Pinned returns the frequency, in MHz, every CPU is pinned to, i.e. with the
+// performance governor and both limits at that frequency, or 0 if they are
+// not all pinned to the same one.
+func Pinned() (int, error) {
+	pinned := 0
+	for _, c := range cpulist.CPUs() {
+		governor, err := Governor(c)
+		if err != nil {
+			return 0, err
+		}
+		min, max, err := Limits(c)
+		if err != nil {
+			return 0, err
+		}
+		if governor != Performance || min != max || (pinned != 0 && min != pinned) {
+			return 0, nil
+		}
+		pinned = min
+	}
+	return pinned, nil
+}
+
+// SetGovernor sets the given governor on every CPU.
+func SetGovernor(governor string) error {
+	for _, c := range cpulist.CPUs() {
+		if err := writeFile(c, "scaling_governor", governor); err != nil {
+			return fmt.Errorf("CPU %d: unable to set the %s governor: %v", c, governor, err)
+		}
+	}
+	return nil
+}
+
+// CommonGovernor returns the governor of every CPU, or Mixed if they do not
+// all have the same one.
+func CommonGovernor() (string, error) {
+	common := ""
+	for _, c := range cpulist.CPUs() {
+		governor, err := Governor(c)
+		if err != nil {
+			return "", err
+		}
+		if common != "" && governor != common {
+			return Mixed, nil
+		}
+		common = governor
+	}
+	return common, nil
+}
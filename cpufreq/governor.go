@@ -0,0 +1,100 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cpufreq
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/setting"
+)
+
+func init() {
+	setting.Register(&governorSetting{})
+}
+
+// governorSetting is the setting with the cpufreq governor of every CPU, e.g.
+// "schedutil".
+type governorSetting struct{}
+
+// Name returns the key of the setting in the config file.
+func (g *governorSetting) Name() string {
+	return "governor"
+}
+
+// Description returns the human-readable name of the setting.
+func (g *governorSetting) Description() string {
+	return "cpufreq governor"
+}
+
+// Values returns the governors available for CPU 0, or a description of the
+// values accepted if they cannot be read.
+func (g *governorSetting) Values() []string {
+	governors, err := Governors(0)
+	if err != nil || len(governors) == 0 {
+		return []string{"any governor listed in scaling_available_governors, e.g. " + Performance}
+	}
+	return governors
+}
+
+// Available reports whether cpufreq is available.
+func (g *governorSetting) Available() error {
+	if !Available() {
+		return errors.New("check if AMD Cool'n'Quiet enabled and cpufreq module loaded")
+	}
+	return nil
+}
+
+// Normalize returns value without surrounding spaces, in lower case.
+func (g *governorSetting) Normalize(value string) string {
+	return strings.ToLower(strings.TrimSpace(value))
+}
+
+// Validate checks value is one of the governors available for CPU 0. If they
+// cannot be read, e.g. when checking a config file on another machine, any
+// value is accepted, and then rejected by the kernel if unknown.
+func (g *governorSetting) Validate(value string) error {
+	value = g.Normalize(value)
+	governors, err := Governors(0)
+	if err != nil {
+		return nil
+	}
+	for _, governor := range governors {
+		if governor == value {
+			return nil
+		}
+	}
+	return fmt.Errorf("governor %q not available; expected one of %s", value, strings.Join(governors, ", "))
+}
+
+// Apply sets the governor value on every CPU.
+func (g *governorSetting) Apply(value string) error {
+	return SetGovernor(g.Normalize(value))
+}
+
+// Status returns the governor of every CPU, or Mixed.
+func (g *governorSetting) Status() (string, error) {
+	return CommonGovernor()
+}
+
+// Mechanism describes the sysfs file with the governor.
+func (g *governorSetting) Mechanism() string {
+	return "sysfs /sys/devices/system/cpu/cpu*/cpufreq/scaling_governor, on every CPU"
+}
+
+// ApplyOrder returns OrderGovernor.
+func (g *governorSetting) ApplyOrder() int {
+	return setting.OrderGovernor
+}
@@ -0,0 +1,172 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cpufreq
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/setting"
+)
+
+// Unpinned is the value of the pin_frequency setting when the frequency is
+// not pinned.
+const Unpinned = "unpinned"
+
+func init() {
+	setting.Register(&pinSetting{})
+}
+
+// pinSetting is the setting pinning the frequency of every CPU. Its value is
+// a frequency, in MHz, e.g. "3800", or Unpinned.
+type pinSetting struct{}
+
+// Name returns the key of the setting in the config file.
+func (p *pinSetting) Name() string {
+	return "pin_frequency"
+}
+
+// Description returns the human-readable name of the setting.
+func (p *pinSetting) Description() string {
+	return "pinned CPU frequency"
+}
+
+// Values returns a description of the values accepted in the config file, as
+// they cannot be listed.
+func (p *pinSetting) Values() []string {
+	return []string{"frequency in MHz, e.g. 3800", Unpinned}
+}
+
+// Available reports whether cpufreq is available, with the performance
+// governor.
+func (p *pinSetting) Available() error {
+	if !Available() {
+		return errors.New("check if AMD Cool'n'Quiet enabled and cpufreq module loaded")
+	}
+	governors, err := Governors(0)
+	if err != nil {
+		return err
+	}
+	for _, g := range governors {
+		if g == Performance {
+			return nil
+		}
+	}
+	return fmt.Errorf("the %s governor is not available", Performance)
+}
+
+// parseMHz parses a frequency in MHz, with an optional MHz suffix.
+func parseMHz(value string) (int, error) {
+	value = strings.TrimSpace(strings.TrimSuffix(strings.ToLower(strings.TrimSpace(value)), "mhz"))
+	mhz, err := strconv.Atoi(value)
+	if err != nil || mhz <= 0 {
+		return 0, fmt.Errorf("invalid frequency %q; expected MHz, e.g. 3800, or %s", value, Unpinned)
+	}
+	return mhz, nil
+}
+
+// Validate checks value is Unpinned or a frequency the hardware supports: one
+// of the available frequencies, for drivers with a discrete set of them, e.g.
+// acpi-cpufreq, or one within the limits of the hardware otherwise.
+func (p *pinSetting) Validate(value string) error {
+	if p.Normalize(value) == Unpinned {
+		return nil
+	}
+	mhz, err := parseMHz(value)
+	if err != nil {
+		return err
+	}
+	frequencies, err := AvailableFrequencies(0)
+	if err == nil && frequencies != nil {
+		for _, f := range frequencies {
+			if f == mhz {
+				return nil
+			}
+		}
+		return fmt.Errorf("frequency %d MHz not supported by the driver; expected one of %s", mhz, joinMHz(frequencies))
+	}
+	min, max, err := HardwareLimits(0)
+	if err != nil {
+		// Out of range frequencies are then rejected by the kernel.
+		return nil
+	}
+	if mhz < min || mhz > max {
+		return fmt.Errorf("frequency %d MHz out of range; expected %d-%d", mhz, min, max)
+	}
+	return nil
+}
+
+// joinMHz returns a list of frequencies, in MHz, separated by commas.
+func joinMHz(frequencies []int) string {
+	values := make([]string, len(frequencies))
+	for i, f := range frequencies {
+		values[i] = strconv.Itoa(f)
+	}
+	return strings.Join(values, ", ")
+}
+
+// Normalize returns value in the form returned by Status, e.g. "3800" for
+// "3800 MHz".
+func (p *pinSetting) Normalize(value string) string {
+	if strings.EqualFold(strings.TrimSpace(value), Unpinned) {
+		return Unpinned
+	}
+	mhz, err := parseMHz(value)
+	if err != nil {
+		return value
+	}
+	return strconv.Itoa(mhz)
+}
+
+// Apply pins the frequency of every CPU to value, or unpins it.
+func (p *pinSetting) Apply(value string) error {
+	if p.Normalize(value) == Unpinned {
+		return Unpin()
+	}
+	mhz, err := parseMHz(value)
+	if err != nil {
+		return err
+	}
+	return Pin(mhz)
+}
+
+// Status returns the frequency every CPU is pinned to, or Unpinned.
+func (p *pinSetting) Status() (string, error) {
+	mhz, err := Pinned()
+	if err != nil {
+		return "", err
+	}
+	if mhz == 0 {
+		return Unpinned, nil
+	}
+	return strconv.Itoa(mhz), nil
+}
+
+// Default returns Unpinned, as cpufreq starts with the limits of the hardware.
+func (p *pinSetting) Default() string {
+	return Unpinned
+}
+
+// Mechanism describes the sysfs files used to pin the frequency.
+func (p *pinSetting) Mechanism() string {
+	return "sysfs /sys/devices/system/cpu/cpu*/cpufreq/scaling_governor, scaling_min_freq and scaling_max_freq, on every CPU"
+}
+
+// ApplyOrder returns OrderPinFreq, so that pinning, which sets the performance
+// governor, comes after the governor setting.
+func (p *pinSetting) ApplyOrder() int {
+	return setting.OrderPinFreq
+}
@@ -0,0 +1,93 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cpuidle
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+const (
+	cpuidleDir            = "/sys/devices/system/cpu/cpuidle"
+	governorFile          = cpuidleDir + "/current_governor"
+	readOnlyGovernorFile  = cpuidleDir + "/current_governor_ro"
+	availableGovernorFile = cpuidleDir + "/available_governors"
+
+	// Menu is the governor picking idle states from the expected idle
+	// duration and latency constraints, and the default on most systems.
+	Menu = "menu"
+	// TEO, the timer events oriented governor, picks idle states mostly
+	// from the next timer event, which some prefer for latency.
+	TEO = "teo"
+	// Ladder steps through the idle states one at a time.
+	Ladder = "ladder"
+	// Haltpoll is meant for virtual machines.
+	Haltpoll = "haltpoll"
+)
+
+var (
+	// ErrUnsupported indicates the kernel does not expose cpuidle, e.g.
+	// when booted with cpuidle.off=1.
+	ErrUnsupported = errors.New("cpuidle not exposed by the kernel")
+
+	// ErrReadOnly indicates the kernel only allows reading the governor,
+	// which then has to be selected with the cpuidle.governor= kernel
+	// parameter.
+	ErrReadOnly = errors.New("the kernel does not allow changing the cpuidle governor at runtime; use the cpuidle.governor= kernel parameter")
+)
+
+// Available returns nil if the cpuidle governor can be changed, or an error
+// explaining why not otherwise, e.g. ErrReadOnly.
+func Available() error {
+	info, err := os.Stat(governorFile)
+	switch {
+	case err == nil && info.Mode().Perm()&0200 != 0:
+		return nil
+	case err == nil:
+		return ErrReadOnly
+	}
+	// Older kernels only have a writable governor file when booted with
+	// cpuidle_sysfs_switch, and a read-only one otherwise.
+	if _, err := os.Stat(readOnlyGovernorFile); err == nil {
+		return ErrReadOnly
+	}
+	return ErrUnsupported
+}
+
+// Governors returns the cpuidle governors available in the kernel.
+func Governors() ([]string, error) {
+	value, err := ioutil.ReadFile(availableGovernorFile)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(value)), nil
+}
+
+// Validate returns nil if governor is one of the governors available in the
+// kernel, or an error listing them otherwise.
+func Validate(governor string) error {
+	governors, err := Governors()
+	if err != nil {
+		return err
+	}
+	for _, g := range governors {
+		if g == governor {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid cpuidle governor %q; expected one of %s", governor, strings.Join(governors, ", "))
+}
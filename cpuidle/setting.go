@@ -0,0 +1,54 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cpuidle
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/setting"
+)
+
+// usualGovernors are the governors found in most kernels.
+var usualGovernors = []string{Menu, TEO, Ladder, Haltpoll}
+
+func init() {
+	setting.Register(&setting.Sysfs{
+		Key:             "idlegovernor",
+		Label:           "cpuidle governor",
+		Path:            governorFile,
+		Accepted:        usualGovernors,
+		Check:           validateGovernor,
+		CaseInsensitive: true,
+		Availability:    Available,
+		Persists:        "lost at reboot, unless set with the cpuidle.governor= kernel parameter",
+		KernelParams:    []string{"cpuidle.governor", "cpuidle.off"},
+		Order:           setting.OrderIdle,
+	})
+}
+
+// validateGovernor checks governor is one of the governors available in the
+// kernel. If they cannot be read, e.g. when checking a config file on another
+// machine, the usual governors are accepted.
+func validateGovernor(governor string) error {
+	if _, err := Governors(); err == nil {
+		return Validate(governor)
+	}
+	for _, g := range usualGovernors {
+		if g == governor {
+			return nil
+		}
+	}
+	return fmt.Errorf("expected one of %s", strings.Join(usualGovernors, ", "))
+}
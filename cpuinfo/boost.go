@@ -0,0 +1,61 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cpuinfo
+
+import (
+	"errors"
+
+	"github.com/klauspost/cpuid"
+)
+
+var (
+	// ErrBoostUnknown indicates the rated maximum boost frequency of the
+	// processor is not known.
+	ErrBoostUnknown = errors.New("rated maximum boost frequency unknown for this processor")
+)
+
+// ratedBoost has the rated maximum boost frequency, in MHz, of each known
+// processor, by model number as it appears in the brand string, from AMD's
+// specifications.
+var ratedBoost = map[string]int{
+	// Zen.
+	"1200": 3400, "1300X": 3700, "1400": 3400, "1500X": 3700, "1600": 3600,
+	"1600X": 4000, "1700": 3700, "1700X": 3800, "1800X": 4000,
+	"1900X": 4000, "1920X": 4000, "1950X": 4000,
+	"2200G": 3700, "2400G": 3900,
+	// Zen+.
+	"2600": 3900, "2600X": 4200, "2700": 4100, "2700X": 4300,
+	"2920X": 4300, "2950X": 4400, "2970WX": 4200, "2990WX": 4200,
+	// Zen 2.
+	"3100": 3900, "3300X": 4300, "3500X": 4100, "3600": 4200, "3600X": 4400,
+	"3600XT": 4500, "3700X": 4400, "3800X": 4500, "3800XT": 4700,
+	"3900X": 4600, "3900XT": 4700, "3950X": 4700,
+	"3960X": 4500, "3970X": 4500, "3990X": 4300,
+	"4600G": 4200, "4650G": 4200, "4700G": 4400, "4750G": 4400,
+	// Zen 3.
+	"5600": 4400, "5600X": 4600, "5600G": 4400, "5700G": 4600, "5700X": 4600,
+	"5800": 4600, "5800X": 4700, "5800X3D": 4500, "5900X": 4800, "5950X": 4900,
+}
+
+// RatedBoost returns the rated maximum boost frequency of the processor, in
+// MHz, as specified by AMD for its model. Like the TDP, it is looked up by
+// the model number in the brand string; ErrBoostUnknown is returned for
+// models not known.
+func RatedBoost() (int, error) {
+	boost, ok := ratedBoost[modelNumber(cpuid.CPU.BrandName)]
+	if !ok {
+		return 0, ErrBoostUnknown
+	}
+	return boost, nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cpuinfo
+
+import (
+	"bufio"
+	"io/ioutil"
+	"math/bits"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	resctrlL3Dir = "/sys/fs/resctrl/info/L3"
+)
+
+// CacheAllocation describes the support for L3 cache allocation, which lets
+// groups of tasks or CPUs use only part of each L3 cache, through the resctrl
+// file system. It is mostly found on server processors, such as EPYC.
+type CacheAllocation struct {
+	// Supported indicates whether the processor supports it.
+	Supported bool
+	// Mounted indicates whether the resctrl file system is mounted, so that
+	// it can be used, in which case Classes and Ways are set.
+	Mounted bool
+	// Classes is how many classes of service, i.e. distinct allocations,
+	// are available.
+	Classes int
+	// Ways is how many ways each L3 cache can be split into.
+	Ways int
+}
+
+// hasFlag returns whether /proc/cpuinfo lists the given flag for the first
+// processor.
+func hasFlag(flag string) (bool, error) {
+	f, err := os.Open(procCPUInfo)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), ":", 2)
+		if len(fields) != 2 || strings.TrimSpace(fields[0]) != "flags" {
+			continue
+		}
+		for _, f := range strings.Fields(fields[1]) {
+			if f == flag {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return false, scanner.Err()
+}
+
+// readResctrlL3 returns a value from the L3 information of resctrl.
+func readResctrlL3(name string) (string, error) {
+	value, err := ioutil.ReadFile(resctrlL3Dir + "/" + name)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(value)), nil
+}
+
+// L3Allocation returns the support for L3 cache allocation, as reported by
+// the kernel in the cat_l3 flag of /proc/cpuinfo and, if mounted, by resctrl.
+func L3Allocation() (CacheAllocation, error) {
+	var allocation CacheAllocation
+	supported, err := hasFlag("cat_l3")
+	if err != nil || !supported {
+		return allocation, err
+	}
+	allocation.Supported = true
+
+	if _, err := os.Stat(resctrlL3Dir); err != nil {
+		return allocation, nil
+	}
+	allocation.Mounted = true
+	value, err := readResctrlL3("num_closids")
+	if err != nil {
+		return allocation, err
+	}
+	if allocation.Classes, err = strconv.Atoi(value); err != nil {
+		return allocation, err
+	}
+	// The capacity bitmask has a bit per way.
+	value, err = readResctrlL3("cbm_mask")
+	if err != nil {
+		return allocation, err
+	}
+	mask, err := strconv.ParseUint(value, 16, 64)
+	if err != nil {
+		return allocation, err
+	}
+	allocation.Ways = bits.OnesCount64(mask)
+	return allocation, nil
+}
@@ -0,0 +1,154 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cpuinfo
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/cpuid"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/cpulist"
+)
+
+const (
+	procCPUInfo = "/proc/cpuinfo"
+)
+
+var (
+	// assumedFamily, if non-zero, overrides the detected family.
+	assumedFamily = 0
+)
+
+var (
+	// ErrFamilyUnsupported is the error, wrapped in a FamilyError, returned
+	// by operations not available on the running processor family. Check for
+	// it with errors.Is.
+	ErrFamilyUnsupported = errors.New("not supported on this processor family")
+)
+
+// FamilyError is returned by operations not available on the running
+// processor family.
+type FamilyError struct {
+	// Operation is what is not supported, e.g. "prefetcher control".
+	Operation string
+	// Family is the processor family in use, as returned by Family.
+	Family int
+}
+
+// Error describes the unsupported operation along with the family.
+func (e *FamilyError) Error() string {
+	return fmt.Sprintf("%s not supported on processor family %Xh", e.Operation, e.Family)
+}
+
+// Unwrap returns ErrFamilyUnsupported, so that errors.Is can detect it.
+func (e *FamilyError) Unwrap() error {
+	return ErrFamilyUnsupported
+}
+
+// RequireFamily returns a FamilyError for the given operation if the
+// processor family in use is older than min, or nil otherwise.
+func RequireFamily(min int, operation string) error {
+	if Family() < min {
+		return &FamilyError{Operation: operation, Family: Family()}
+	}
+	return nil
+}
+
+// RequireFamilyIn returns a FamilyError for the given operation unless the
+// processor family in use is one of families, or nil otherwise. Unlike
+// RequireFamily, newer families are not assumed to be compatible, which suits
+// operations on registers whose layout is only documented for some families.
+func RequireFamilyIn(operation string, families ...int) error {
+	for _, f := range families {
+		if Family() == f {
+			return nil
+		}
+	}
+	return &FamilyError{Operation: operation, Family: Family()}
+}
+
+// DetectedFamily returns the processor family, as reported by CPUID.
+func DetectedFamily() int {
+	return cpuid.CPU.Family
+}
+
+// Model returns the processor model, as reported by CPUID. Unlike the family,
+// it cannot be assumed.
+func Model() int {
+	return cpuid.CPU.Model
+}
+
+// Family returns the processor family to be used for feature gating. This is
+// the detected family, unless another one was assumed with AssumeFamily.
+func Family() int {
+	if assumedFamily != 0 {
+		return assumedFamily
+	}
+	return DetectedFamily()
+}
+
+// AssumeFamily overrides the detected processor family for the purpose of
+// feature gating. This helps with firmware for which CPUID misreports the
+// family. Passing 0 restores the detected family.
+func AssumeFamily(family int) {
+	assumedFamily = family
+}
+
+// Stepping returns the processor stepping, as reported in /proc/cpuinfo for
+// the first processor.
+func Stepping() (int, error) {
+	f, err := os.Open(procCPUInfo)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), ":", 2)
+		if len(fields) != 2 || strings.TrimSpace(fields[0]) != "stepping" {
+			continue
+		}
+		return strconv.Atoi(strings.TrimSpace(fields[1]))
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("no stepping found in %s", procCPUInfo)
+}
+
+// L3Complexes returns the groups of CPUs sharing an L3 cache, i.e. the core
+// complexes (CCX). On Zen 3 and later, each CCD has a single CCX; on Zen 2,
+// it has two.
+func L3Complexes() ([]string, error) {
+	seen := map[string]bool{}
+	var complexes []string
+	for _, c := range cpulist.CPUs() {
+		value, err := ioutil.ReadFile(fmt.Sprintf("%s/cpu%d/cache/index3/shared_cpu_list", cpuDir, c))
+		if err != nil {
+			return nil, err
+		}
+		group := strings.TrimSpace(string(value))
+		if !seen[group] {
+			seen[group] = true
+			complexes = append(complexes, group)
+		}
+	}
+	return complexes, nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cpuinfo
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRequireFamily(t *testing.T) {
+	defer AssumeFamily(0)
+
+	tests := []struct {
+		family, min int
+		supported   bool
+	}{
+		{0x17, 0x17, true},
+		{0x19, 0x17, true},
+		{0x15, 0x17, false},
+		{0x17, 0x19, false},
+	}
+	for _, tt := range tests {
+		AssumeFamily(tt.family)
+		err := RequireFamily(tt.min, "testing")
+		if tt.supported {
+			if err != nil {
+				t.Errorf("family %Xh, min %Xh: RequireFamily() = %v, want nil", tt.family, tt.min, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrFamilyUnsupported) {
+			t.Errorf("family %Xh, min %Xh: RequireFamily() = %v, want ErrFamilyUnsupported", tt.family, tt.min, err)
+		}
+		var familyErr *FamilyError
+		if !errors.As(err, &familyErr) || familyErr.Family != tt.family || familyErr.Operation != "testing" {
+			t.Errorf("family %Xh, min %Xh: RequireFamily() = %#v, want a FamilyError for family %Xh", tt.family, tt.min, err, tt.family)
+		}
+	}
+}
+
+func TestRequireFamilyIn(t *testing.T) {
+	defer AssumeFamily(0)
+
+	for _, family := range []int{0x17, 0x19} {
+		AssumeFamily(family)
+		if err := RequireFamilyIn("testing", 0x17, 0x19); err != nil {
+			t.Errorf("family %Xh: RequireFamilyIn() = %v, want nil", family, err)
+		}
+	}
+	// Newer families are not assumed to be compatible.
+	for _, family := range []int{0x15, 0x1A} {
+		AssumeFamily(family)
+		if err := RequireFamilyIn("testing", 0x17, 0x19); !errors.Is(err, ErrFamilyUnsupported) {
+			t.Errorf("family %Xh: RequireFamilyIn() = %v, want ErrFamilyUnsupported", family, err)
+		}
+	}
+}
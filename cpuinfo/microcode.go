@@ -0,0 +1,68 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cpuinfo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/cpulist"
+)
+
+const (
+	cpuDir = "/sys/devices/system/cpu"
+)
+
+// MicrocodeAvailable returns a boolean indicating whether the kernel exposes
+// the microcode revision of the CPUs.
+func MicrocodeAvailable() bool {
+	if _, err := os.Stat(cpuDir + "/cpu0/microcode/version"); err == nil {
+		return true
+	}
+	return false
+}
+
+// MicrocodeRevision returns the microcode revision the given CPU is running.
+func MicrocodeRevision(cpu int) (uint64, error) {
+	value, err := ioutil.ReadFile(fmt.Sprintf("%s/cpu%d/microcode/version", cpuDir, cpu))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(value)), 0, 64)
+}
+
+// MicrocodeRevisions returns every microcode revision found across the CPUs,
+// in ascending order, along with the CPUs running each of them. Normally
+// there is a single revision; more than one means the cores may behave
+// inconsistently, e.g. when writing MSRs.
+func MicrocodeRevisions() ([]uint64, map[uint64][]int, error) {
+	cpus := map[uint64][]int{}
+	var revisions []uint64
+	for _, c := range cpulist.CPUs() {
+		revision, err := MicrocodeRevision(c)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, ok := cpus[revision]; !ok {
+			revisions = append(revisions, revision)
+		}
+		cpus[revision] = append(cpus[revision], c)
+	}
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i] < revisions[j] })
+	return revisions, cpus, nil
+}
@@ -0,0 +1,34 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build simulate
+// +build simulate
+
+package cpuinfo
+
+import (
+	"github.com/klauspost/cpuid"
+)
+
+// Simulated is true when built with the simulate tag, in which case the MSRs
+// are kept in memory and CPUID reports a fixed Zen 2 processor, so that the
+// program can be exercised on any machine.
+const Simulated = true
+
+func init() {
+	cpuid.CPU.VendorID = cpuid.AMD
+	cpuid.CPU.VendorString = "AuthenticAMD"
+	cpuid.CPU.BrandName = "AMD Ryzen 7 3700X 8-Core Processor"
+	cpuid.CPU.Family = 0x17
+	cpuid.CPU.Model = 0x71
+}
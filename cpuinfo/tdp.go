@@ -0,0 +1,74 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cpuinfo
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/klauspost/cpuid"
+)
+
+var (
+	// ErrTDPUnknown indicates the rated TDP of the processor is not known.
+	ErrTDPUnknown = errors.New("rated TDP unknown for this processor")
+)
+
+// ratedTDP has the rated TDP, in watts, of each known processor, by model
+// number as it appears in the brand string, from AMD's specifications.
+var ratedTDP = map[string]int{
+	// Zen.
+	"1200": 65, "1300X": 65, "1400": 65, "1500X": 65, "1600": 65,
+	"1600X": 95, "1700": 65, "1700X": 95, "1800X": 95,
+	"1900X": 180, "1920X": 180, "1950X": 180,
+	"2200G": 65, "2400G": 65,
+	// Zen+.
+	"2600": 65, "2600X": 95, "2700": 65, "2700X": 105,
+	"2920X": 180, "2950X": 180, "2970WX": 250, "2990WX": 250,
+	// Zen 2.
+	"3100": 65, "3300X": 65, "3500X": 65, "3600": 65, "3600X": 95,
+	"3600XT": 95, "3700X": 65, "3800X": 105, "3800XT": 105,
+	"3900X": 105, "3900XT": 105, "3950X": 105,
+	"3960X": 280, "3970X": 280, "3990X": 280,
+	"4600G": 65, "4650G": 65, "4700G": 65, "4750G": 65,
+	// Zen 3.
+	"5600": 65, "5600X": 65, "5600G": 65, "5700G": 65, "5700X": 65,
+	"5800": 65, "5800X": 105, "5800X3D": 105, "5900X": 105, "5950X": 105,
+}
+
+// modelNumber returns the model number in a brand string, e.g. "3700X" in
+// "AMD Ryzen 7 3700X 8-Core Processor", or an empty string if there is none.
+func modelNumber(brand string) string {
+	fields := strings.Fields(brand)
+	for i, f := range fields {
+		// The model number follows the series, e.g. "Ryzen 7" or
+		// "Ryzen Threadripper".
+		if f == "Ryzen" && i+2 < len(fields) {
+			return strings.TrimSuffix(fields[i+2], "-Core")
+		}
+	}
+	return ""
+}
+
+// RatedTDP returns the rated, i.e. default, TDP of the processor in watts, as
+// specified by AMD for its model. The processor does not report it, so it is
+// looked up by the model number in the brand string; ErrTDPUnknown is
+// returned for models not known.
+func RatedTDP() (int, error) {
+	tdp, ok := ratedTDP[modelNumber(cpuid.CPU.BrandName)]
+	if !ok {
+		return 0, ErrTDPUnknown
+	}
+	return tdp, nil
+}
@@ -0,0 +1,242 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cpulist
+
+import (
+	"fmt"
+	"io/ioutil"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	cpuDir = "/sys/devices/system/cpu"
+
+	// onlinePollInterval is how often WaitOnline checks the online CPUs.
+	onlinePollInterval = 100 * time.Millisecond
+
+	// onlineSettleTime is how long the online CPUs must stay the same for
+	// WaitOnline to consider the others offline on purpose.
+	onlineSettleTime = 2 * time.Second
+)
+
+var (
+	// maxCPUs, if non-zero, caps how many CPUs per-core operations act on.
+	maxCPUs = 0
+
+	// allHostCPUs indicates per-core operations should act on every online
+	// CPU, even the ones we are not allowed to run on.
+	allHostCPUs = false
+
+	// allowedCPUs are the CPUs we are allowed to run on, e.g. as restricted
+	// by the cpuset of the container we run in, or nil if unknown. They are
+	// read at startup, before we change our own affinity, and updated by
+	// WaitOnline.
+	allowedCPUs = readAllowed()
+)
+
+// cpuMask is a CPU set, as used by sched_getaffinity(2).
+type cpuMask [16]uint64
+
+// readAllowed returns the CPUs in the affinity mask of this process, which
+// the kernel restricts to the effective cgroup cpuset, or nil if it cannot be
+// read.
+func readAllowed() []int {
+	var mask cpuMask
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_GETAFFINITY, 0, unsafe.Sizeof(mask), uintptr(unsafe.Pointer(&mask[0])))
+	if errno != 0 {
+		return nil
+	}
+	var cpus []int
+	for c := 0; c < len(mask)*64; c++ {
+		if mask[c/64]&(1<<uint(c%64)) != 0 {
+			cpus = append(cpus, c)
+		}
+	}
+	return cpus
+}
+
+// CPUs returns the CPUs per-core operations should act on: the online ones we
+// are allowed to run on, unless SetAllHostCPUs says otherwise, capped with
+// SetMax.
+func CPUs() []int {
+	online, err := Online()
+	if err != nil {
+		// Without sysfs, we can only count on what the runtime found.
+		online = nil
+		for c := 0; c < runtime.NumCPU(); c++ {
+			online = append(online, c)
+		}
+	}
+
+	cpus := online
+	if !allHostCPUs && allowedCPUs != nil {
+		allowed := map[int]bool{}
+		for _, c := range allowedCPUs {
+			allowed[c] = true
+		}
+		cpus = nil
+		for _, c := range online {
+			if allowed[c] {
+				cpus = append(cpus, c)
+			}
+		}
+	}
+	if maxCPUs > 0 && maxCPUs < len(cpus) {
+		return cpus[:maxCPUs]
+	}
+	return cpus
+}
+
+// Count returns how many CPUs per-core operations should act on, i.e. the
+// length of CPUs.
+func Count() int {
+	return len(CPUs())
+}
+
+// Includes returns whether per-core operations should act on the given CPU.
+func Includes(cpu int) bool {
+	for _, c := range CPUs() {
+		if c == cpu {
+			return true
+		}
+	}
+	return false
+}
+
+// SetAllHostCPUs makes per-core operations act on every online CPU of the
+// host if all is true, instead of only on the ones we are allowed to run on,
+// e.g. in a container.
+func SetAllHostCPUs(all bool) {
+	allHostCPUs = all
+}
+
+// SetMax caps per-core operations to the first n of their CPUs, which is
+// handy for quick experiments on processors with many cores. Passing 0
+// removes the cap.
+func SetMax(n int) {
+	maxCPUs = n
+}
+
+// Parse parses a list of CPUs in the format used by the kernel, e.g. "0-3,8",
+// returning the CPUs in the order given.
+func Parse(list string) ([]int, error) {
+	var cpus []int
+	for _, part := range strings.Split(list, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		bounds := strings.SplitN(part, "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil || first < 0 {
+			return nil, fmt.Errorf("invalid CPU %q", bounds[0])
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = strconv.Atoi(bounds[1]); err != nil || last < first {
+				return nil, fmt.Errorf("invalid CPU range %q", part)
+			}
+		}
+		for c := first; c <= last; c++ {
+			cpus = append(cpus, c)
+		}
+	}
+	if len(cpus) == 0 {
+		return nil, fmt.Errorf("empty CPU list %q", list)
+	}
+	return cpus, nil
+}
+
+// Format formats a sorted list of CPUs in the format used by the kernel, e.g.
+// "0-3,8".
+func Format(cpus []int) string {
+	var parts []string
+	for i := 0; i < len(cpus); {
+		j := i
+		for j+1 < len(cpus) && cpus[j+1] == cpus[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, strconv.Itoa(cpus[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", cpus[i], cpus[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+// Present returns the CPUs present in the system, whether online or not.
+func Present() ([]int, error) {
+	return readList("present")
+}
+
+// Online returns the CPUs currently online.
+func Online() ([]int, error) {
+	return readList("online")
+}
+
+// ThreadSiblings returns the CPUs that are SMT threads of the same physical
+// core as the given CPU, including the CPU itself.
+func ThreadSiblings(cpu int) ([]int, error) {
+	return readList(fmt.Sprintf("cpu%d/topology/thread_siblings_list", cpu))
+}
+
+// readList reads one of the CPU lists kept by the kernel, e.g. "online".
+func readList(name string) ([]int, error) {
+	value, err := ioutil.ReadFile(cpuDir + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(string(value))
+}
+
+// WaitOnline waits, up to timeout, until the CPUs coming online, which may
+// not all be online yet early at boot, are done with it: until every present
+// CPU is online, or the online CPUs stay the same for onlineSettleTime. The
+// latter covers CPUs that are offline on purpose, e.g. with `nosmt' or
+// `maxcpus=' on the kernel command line, or brought offline deliberately,
+// which never come online.
+func WaitOnline(timeout time.Duration) error {
+	present, err := Present()
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(timeout)
+	var last []int
+	settled := time.Now()
+	for {
+		online, err := Online()
+		if err != nil {
+			return err
+		}
+		if Format(online) != Format(last) {
+			last, settled = online, time.Now()
+		}
+		if len(online) >= len(present) || time.Since(settled) >= onlineSettleTime {
+			// CPUs coming online may have been added to our cpuset.
+			allowedCPUs = readAllowed()
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("CPUs still coming online after %v, %d of %d so far", timeout, len(online), len(present))
+		}
+		time.Sleep(onlinePollInterval)
+	}
+}
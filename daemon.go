@@ -0,0 +1,196 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// daemonEvent is a line of the event stream the daemon produces with
+// -format json, in place of its log, one JSON object per line.
+type daemonEvent struct {
+	Time string `json:"timestamp"`
+	// Event is one of poll, apply, drift, resume, status and error.
+	Event   string `json:"event"`
+	Setting string `json:"setting,omitempty"`
+	// Observed is the value found, and Corrected the one applied.
+	Observed  string        `json:"observed,omitempty"`
+	Corrected string        `json:"corrected,omitempty"`
+	Result    string        `json:"result,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	Summary   *applySummary `json:"summary,omitempty"`
+	Hook      *hookResult   `json:"post_apply,omitempty"`
+	// Status is the status of every setting, for status events.
+	Status []settingStatus `json:"status,omitempty"`
+}
+
+// eventMu serializes the lines of the event stream, as the drift hooks emit
+// theirs from goroutines of their own.
+var eventMu sync.Mutex
+
+// emitEvent writes e, timestamped, as a line of the event stream.
+func emitEvent(e daemonEvent) {
+	eventMu.Lock()
+	defer eventMu.Unlock()
+	e.Time = time.Now().Format(time.RFC3339)
+	buf, err := json.Marshal(e)
+	if err != nil {
+		fmt.Printf("{\"timestamp\":%q,\"event\":\"error\",\"error\":%q}\n", e.Time, err.Error())
+		return
+	}
+	fmt.Println(string(buf))
+}
+
+// emitApplyEvents writes the event stream lines for report: a drift event for
+// each setting in drifted, an apply event for every other setting that was
+// not already set, and a poll event summarizing them.
+func emitApplyEvents(report *applyReport, drifted map[string]bool) {
+	for _, r := range report.Results {
+		if r.Result == resultAlreadySet {
+			continue
+		}
+		e := daemonEvent{Event: "apply", Setting: r.Setting, Observed: r.Previous, Corrected: r.Value, Result: r.Result, Error: r.Error}
+		if drifted[r.Setting] {
+			e.Event = "drift"
+		}
+		if r.Result != resultChanged {
+			e.Corrected = ""
+		}
+		emitEvent(e)
+	}
+	summary := report.Summary
+	emitEvent(daemonEvent{Event: "poll", Summary: &summary, Hook: report.Hook})
+}
+
+// daemonApply reloads the configuration and applies it, reporting the outcome
+// only if something changed or failed, unless always is true. A setting that
+// had to be changed again, although it still has the value we last applied,
+// as recorded in last, was changed by someone else, which is reported as
+// such, and to the drift hooks. With -format json, every apply is reported,
+// as events, instead; see emitApplyEvents. It returns the configuration, or
+// the previous one, prev, if reloading failed.
+func daemonApply(configFile, configDir string, filter *settingFilter, prev *configuration, last map[string]string, always bool) *configuration {
+	cfg, err := loadConfiguration(configFile, configDir)
+	if err != nil {
+		if jsonOutput {
+			emitEvent(daemonEvent{Event: "error", Error: err.Error()})
+		} else {
+			fmt.Printf("%s: Error: %v.\n", time.Now().Format(time.RFC3339), err)
+		}
+		if prev == nil {
+			return nil
+		}
+		// Keep enforcing the last configuration we could load.
+		cfg = prev
+	}
+
+	report := &applyReport{}
+	handleConfiguration(cfg, filter, report)
+	if report.eventful() {
+		runPostApplyHook(cfg, report)
+	}
+
+	drifted := map[string]bool{}
+	for _, r := range report.Results {
+		if r.Result == resultChanged && last[r.Setting] == r.Value {
+			drifted[r.Setting] = true
+			notifyDrift(cfg, r)
+		}
+		switch r.Result {
+		case resultChanged, resultAlreadySet:
+			last[r.Setting] = r.Value
+		}
+	}
+	if jsonOutput {
+		emitApplyEvents(report, drifted)
+		return cfg
+	}
+
+	for _, r := range report.Results {
+		if drifted[r.Setting] {
+			found := "was changed"
+			if r.Previous != "" {
+				found = fmt.Sprintf("was found as %q", r.Previous)
+			}
+			fmt.Printf("%s: External change: %s %s, instead of %q as last applied; applied again.\n", time.Now().Format(time.RFC3339), r.Setting, found, r.Value)
+		}
+	}
+	if !report.eventful() && !always {
+		return cfg
+	}
+
+	fmt.Printf("%s: %s.\n", time.Now().Format(time.RFC3339), report.Summary)
+	for _, r := range report.Results {
+		if r.Result != resultAlreadySet {
+			fmt.Println(resultLine(r))
+		}
+	}
+	return cfg
+}
+
+// daemon keeps applying the configuration every interval, so that changes
+// made behind our back, e.g. by firmware on resume, are reverted. It also
+// applies it right after a resume from suspend, reporting the settings that
+// did not survive it. SIGUSR1 triggers an immediate apply, and SIGUSR2
+// displays the current status. With -format json, the log is replaced by a
+// stream of JSON objects, one per line, meant for log ingestion.
+func daemon(configFile, configDir string, filter *settingFilter, interval time.Duration) {
+	// Only what changed or failed is reported, so the log stays readable.
+	quietSuccess = true
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	if !jsonOutput {
+		fmt.Printf("Applying the configuration every %v; send SIGUSR1 to apply now, SIGUSR2 to display the status.\n", interval)
+	}
+	// The value last applied to each setting, to tell external changes.
+	last := map[string]string{}
+	cfg := daemonApply(configFile, configDir, filter, nil, last, true)
+
+	resumed := watchResume()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			cfg = daemonApply(configFile, configDir, filter, cfg, last, false)
+		case <-resumed:
+			if jsonOutput {
+				emitEvent(daemonEvent{Event: "resume"})
+			} else {
+				fmt.Printf("%s: Resumed from suspend; applying the configuration again.\n", time.Now().Format(time.RFC3339))
+			}
+			cfg = daemonApply(configFile, configDir, filter, cfg, last, true)
+		case sig := <-signals:
+			switch sig {
+			case syscall.SIGUSR1:
+				cfg = daemonApply(configFile, configDir, filter, cfg, last, true)
+			case syscall.SIGUSR2:
+				if jsonOutput {
+					emitEvent(daemonEvent{Event: "status", Status: statusEntries(cfg)})
+					continue
+				}
+				fmt.Printf("\n--- %s ---", time.Now().Format(time.RFC1123))
+				showStatus(cfg)
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Exit codes of -diff-exit-code, as with terraform plan -detailed-exitcode.
+const (
+	driftNone    = 0
+	driftError   = 1
+	driftChanges = 2
+)
+
+// driftEntry is a configured setting that differs from the config, or that
+// could not be checked, in which case Error explains why.
+type driftEntry struct {
+	Setting    string `json:"setting"`
+	Configured string `json:"configured"`
+	Current    string `json:"current,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// driftReport is the outcome of comparing the current state to the config.
+type driftReport struct {
+	Drifted     bool         `json:"drifted"`
+	Differences []driftEntry `json:"differences"`
+	Unchecked   []driftEntry `json:"unchecked,omitempty"`
+}
+
+// checkDrift compares the current state to the one described by the config
+// file, without changing anything, and reports the differences for the
+// settings the filter allows. It returns driftChanges if any setting differs,
+// driftError if some could not be checked, and driftNone otherwise.
+func checkDrift(configFile, configDir string, filter *settingFilter) int {
+	if err := sanityCheck(false); err != nil {
+		fmt.Printf("Error: %v.\n", err)
+		return driftError
+	}
+	cfg, err := loadConfiguration(configFile, configDir)
+	if err != nil {
+		fmt.Printf("Error: %v.\n", err)
+		return driftError
+	}
+
+	report := &driftReport{Differences: []driftEntry{}}
+	for _, c := range compareSettings(cfg) {
+		if !c.configured || !filter.allows(c.setting.Name()) {
+			continue
+		}
+		entry := driftEntry{
+			Setting:    c.setting.Name(),
+			Configured: displayValue(c.setting, c.desired),
+			Current:    displayValue(c.setting, c.current),
+		}
+		switch {
+		case c.unavailable != nil:
+			entry.Current, entry.Error = "", c.unavailable.Error()
+			report.Unchecked = append(report.Unchecked, entry)
+		case c.err != nil:
+			entry.Current, entry.Error = "", c.err.Error()
+			report.Unchecked = append(report.Unchecked, entry)
+		case c.mismatch():
+			report.Differences = append(report.Differences, entry)
+		}
+	}
+	report.Drifted = len(report.Differences) > 0
+
+	if jsonOutput {
+		buf, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			fmt.Printf("Error: unable to produce JSON output: %v.\n", err)
+			return driftError
+		}
+		fmt.Println(string(buf))
+	} else {
+		for _, d := range report.Differences {
+			fmt.Printf("%s: configured %s, currently %s\n", d.Setting, d.Configured, d.Current)
+		}
+		for _, u := range report.Unchecked {
+			fmt.Printf("%s: unable to check: %s\n", u.Setting, u.Error)
+		}
+		switch n := len(report.Differences); {
+		case n == 1:
+			fmt.Println("1 setting differs from the config.")
+		case n > 1:
+			fmt.Printf("%d settings differ from the config.\n", n)
+		default:
+			fmt.Println("Every configured setting matches the config.")
+		}
+	}
+
+	switch {
+	case report.Drifted:
+		return driftChanges
+	case len(report.Unchecked) > 0:
+		return driftError
+	}
+	return driftNone
+}
@@ -0,0 +1,145 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	// onDriftKey is the config key holding the command the daemon runs
+	// when it corrects a setting changed by someone else.
+	onDriftKey = "on_drift"
+
+	// onDriftURLKey is the config key holding the URL the daemon posts to
+	// when it corrects a setting changed by someone else.
+	onDriftURLKey = "on_drift_url"
+
+	// driftHookTimeout bounds how long the drift hooks may take.
+	driftHookTimeout = 10 * time.Second
+)
+
+// driftEvent describes a setting changed by someone else, and corrected.
+type driftEvent struct {
+	Setting string `json:"setting"`
+	// Before is the value found, and After the one applied again.
+	Before string `json:"before"`
+	After  string `json:"after"`
+	Time   string `json:"time"`
+	Host   string `json:"host"`
+}
+
+// newDriftEvent returns the event describing the correction of r.
+func newDriftEvent(r applyResult) driftEvent {
+	host, _ := os.Hostname()
+	return driftEvent{
+		Setting: r.Setting,
+		Before:  r.Previous,
+		After:   r.Value,
+		Time:    time.Now().Format(time.RFC3339),
+		Host:    host,
+	}
+}
+
+// runDriftCommand runs command with the event in the environment, as
+// RYZEN_STABILIZATOR_DRIFT_SETTING, _BEFORE and _AFTER.
+func runDriftCommand(ctx context.Context, command string, event driftEvent) error {
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	cmd.Env = append(os.Environ(),
+		hookEnvPrefix+"DRIFT_SETTING="+event.Setting,
+		hookEnvPrefix+"DRIFT_BEFORE="+event.Before,
+		hookEnvPrefix+"DRIFT_AFTER="+event.After,
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// postDriftEvent posts the event, as JSON, to url.
+func postDriftEvent(ctx context.Context, url string, event driftEvent) error {
+	buf, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected HTTP status %q", resp.Status)
+	}
+	return nil
+}
+
+// notifyDrift runs the drift hooks from the configuration, if any, for the
+// correction of r. They run in the background, within driftHookTimeout, so
+// that a slow one does not stall the daemon; failures are logged.
+func notifyDrift(cfg *configuration, r applyResult) {
+	command, _ := cfg.settings.value(onDriftKey)
+	url, _ := cfg.settings.value(onDriftURLKey)
+	if command == "" && url == "" {
+		return
+	}
+
+	event := newDriftEvent(r)
+	if command != "" {
+		// Like the post-apply hook, the command runs as root.
+		file := strings.TrimPrefix(cfg.sources[onDriftKey], "file:")
+		if err := cfg.checkHookOwner(file); err != nil {
+			driftHookError(event, fmt.Sprintf("refusing to run: %v", err))
+			command = ""
+		}
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), driftHookTimeout)
+		defer cancel()
+		if command != "" {
+			if err := runDriftCommand(ctx, command, event); err != nil {
+				driftHookError(event, fmt.Sprintf("%q failed for %s: %v", command, event.Setting, err))
+			}
+		}
+		if url != "" {
+			if err := postDriftEvent(ctx, url, event); err != nil {
+				driftHookError(event, fmt.Sprintf("unable to notify %q for %s: %v", url, event.Setting, err))
+			}
+		}
+	}()
+}
+
+// driftHookError logs msg, about a drift hook run for event, or emits it as
+// an error event with -format json.
+func driftHookError(event driftEvent, msg string) {
+	if jsonOutput {
+		emitEvent(daemonEvent{Event: "error", Setting: event.Setting, Error: "drift hook: " + msg})
+		return
+	}
+	fmt.Printf("%s: Drift hook: %s.\n", time.Now().Format(time.RFC3339), msg)
+}
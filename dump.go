@@ -0,0 +1,103 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/msr"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/pmtable"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/smu"
+)
+
+const (
+	// maxMSRDump bounds how many MSRs -dump-msr-range reads at once.
+	maxMSRDump = 256
+)
+
+// parseMSRRange parses a range of MSRs, e.g. "0xC0010000-0xC0010020", with
+// both ends included. A single MSR is also accepted.
+func parseMSRRange(r string) (first, last int64, err error) {
+	bounds := strings.SplitN(r, "-", 2)
+	if first, err = strconv.ParseInt(strings.TrimSpace(bounds[0]), 0, 64); err != nil {
+		return 0, 0, fmt.Errorf("invalid MSR %q", bounds[0])
+	}
+	last = first
+	if len(bounds) == 2 {
+		if last, err = strconv.ParseInt(strings.TrimSpace(bounds[1]), 0, 64); err != nil {
+			return 0, 0, fmt.Errorf("invalid MSR %q", bounds[1])
+		}
+	}
+	switch {
+	case first < 0 || last < first:
+		return 0, 0, fmt.Errorf("invalid MSR range %q", r)
+	case last-first+1 > maxMSRDump:
+		return 0, 0, fmt.Errorf("MSR range %q too large; at most %d MSRs can be dumped at once", r, maxMSRDump)
+	}
+	return first, last, nil
+}
+
+// dumpMSRRange displays, in hex, the MSRs in the given range of the given
+// CPU. Registers that cannot be read, e.g. because they do not exist, are
+// marked as such instead of stopping the dump.
+func dumpMSRRange(r string, cpu int) error {
+	first, last, err := parseMSRRange(r)
+	if err != nil {
+		return err
+	}
+	if !msr.Available() {
+		return msr.Check()
+	}
+
+	fmt.Printf("MSRs 0x%X-0x%X of cpu%d:\n", first, last, cpu)
+	for offset := first; offset <= last; offset++ {
+		value, err := msr.Read(offset, cpu)
+		if err != nil {
+			fmt.Printf("0x%08X: unreadable (%v)\n", offset, err)
+			continue
+		}
+		fmt.Printf("0x%08X: 0x%016X\n", offset, value)
+	}
+	return nil
+}
+
+// dumpPMTable displays every entry of the SMU power management (PM) table,
+// with its byte offset, naming the known fields if the layout for the table
+// version is known.
+func dumpPMTable() error {
+	if !smu.Available() {
+		return errors.New("SMU unavailable; check if the ryzen_smu module is loaded")
+	}
+	table, err := pmtable.Read()
+	if err != nil {
+		return err
+	}
+
+	layout := "unknown layout"
+	if table.Known() {
+		layout = "known layout"
+	}
+	fmt.Printf("PM table version 0x%06X (%s), %d entries:\n", table.Version, layout, len(table.Entries))
+	for i, value := range table.Entries {
+		line := fmt.Sprintf("%4d  0x%04X  %14.4f", i, 4*i, value)
+		if f, ok := table.FieldAt(i); ok {
+			line += "  " + f.String()
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
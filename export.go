@@ -0,0 +1,116 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/setting"
+)
+
+// exportConfiguration writes to path a config file that reproduces the
+// current status of every available setting, when applied. Each setting comes
+// with comments describing it, its accepted values and its default, if known.
+// With complete, the file is meant as a template instead: it also has the
+// settings that are unavailable here, or whose status cannot be read, set to
+// their default and commented out, and the options, commented out too.
+func exportConfiguration(path string, complete bool) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Configuration file for %s.\n", program)
+	fmt.Fprintf(&buf, "# Exported from the current state on %s.\n", time.Now().Format(time.RFC1123))
+	if complete {
+		fmt.Fprintf(&buf, "# Every setting is listed, with its current value, or, commented out, its\n# default if the current value is unknown; remove the settings you do not\n# want ryzen-stabilizator to manage.\n\n")
+	} else {
+		fmt.Fprintf(&buf, "# The values below are the current ones; remove the settings you do not\n# want ryzen-stabilizator to manage.\n\n")
+	}
+
+	for _, s := range setting.All() {
+		available := s.Available()
+		if available != nil && !complete {
+			continue
+		}
+		// Each setting is described, so that the file documents itself.
+		fmt.Fprintf(&buf, "# %s. Accepted values: %s.\n", capitalize(s.Description()), strings.Join(s.Values(), ", "))
+		def, hasDefault := setting.Default(s)
+		if hasDefault {
+			fmt.Fprintf(&buf, "# Default at boot: %q.\n", def)
+		}
+		if complete {
+			fmt.Fprintf(&buf, "# Changed through %s; %s.\n", setting.Mechanism(s), setting.Persistence(s))
+		}
+		if available != nil {
+			fmt.Fprintf(&buf, "# Unavailable on this machine: %v.\n#%s = %q\n\n", available, s.Name(), def)
+			continue
+		}
+		status, err := s.Status()
+		if err != nil {
+			// Leave a note, so it is clear the setting was not forgotten.
+			fmt.Fprintf(&buf, "# %s: unable to read status: %v\n", s.Name(), err)
+			if complete {
+				fmt.Fprintf(&buf, "#%s = %q\n", s.Name(), def)
+			}
+			fmt.Fprintln(&buf)
+			continue
+		}
+		fmt.Fprintf(&buf, "%s = %q\n\n", s.Name(), status)
+	}
+
+	if complete {
+		exportOptions(&buf)
+	}
+	return writeFile(path, buf.Bytes(), configFileMode)
+}
+
+// optionExamples are example values of the options, as written by
+// exportOptions, in the order they are written.
+var optionExamples = []struct {
+	key, value string
+}{
+	{strictKey, "true"},
+	{minKernelKey, `"6.1"`},
+	{postApplyKey, `"logger -t ryzen-stabilizator \"applied $RYZEN_STABILIZATOR_SETTINGS\""`},
+	{onDriftKey, `"logger -t ryzen-stabilizator \"$RYZEN_STABILIZATOR_DRIFT_SETTING drifted\""`},
+	{onDriftURLKey, `"https://alerts.example.com/hooks/ryzen"`},
+	{selfNiceKey, "10"},
+	{selfAffinityKey, `"0-1"`},
+	{selfUmaskKey, `"077"`},
+}
+
+// exportOptions writes to buf the options, which are not settings, commented
+// out with an example value, along with the [extra] table, which has to be
+// the last one. They are described as in the JSON Schema of the config file.
+func exportOptions(buf *bytes.Buffer) {
+	properties := configSchema()["properties"].(map[string]interface{})
+	self := properties["self"].(map[string]interface{})["properties"].(map[string]interface{})
+	describe := func(key string) {
+		schema, ok := properties[key].(map[string]interface{})
+		if !ok {
+			schema, ok = self[strings.TrimPrefix(key, "self.")].(map[string]interface{})
+		}
+		if description, ok := schema["description"].(string); ok {
+			fmt.Fprintf(buf, "# %s\n", description)
+		}
+	}
+
+	fmt.Fprintf(buf, "# Options about how ryzen-stabilizator itself runs.\n\n")
+	for _, o := range optionExamples {
+		describe(o.key)
+		fmt.Fprintf(buf, "#%s = %s\n\n", o.key, o.value)
+	}
+	describe("extra")
+	fmt.Fprintf(buf, "#[extra]\n#\"/sys/block/nvme0n1/queue/scheduler\" = \"none\"\n")
+}
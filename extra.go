@@ -0,0 +1,69 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/setting"
+)
+
+const (
+	// extraPrefix prefixes the keys of the [extra] table, which maps sysfs
+	// paths to the values to write to them, e.g. to select an I/O scheduler:
+	//
+	//   [extra]
+	//   "/sys/block/nvme0n1/queue/scheduler" = "none"
+	extraPrefix = "extra."
+)
+
+// extraSetting returns the setting for a sysfs file from the [extra] table.
+// It is not registered, as it only exists for the configuration that
+// mentions it, and accepts any value, leaving validation to the kernel.
+func extraSetting(path string) setting.Setting {
+	return &setting.Sysfs{Key: extraPrefix + path, Label: path, Path: path}
+}
+
+// extraRefused returns why the sysfs file of the [extra] table with the given
+// key is not written, or "" if it may be. Files are not written from a config
+// fetched over plain HTTP, as anyone on the network could have changed it.
+func (c *configuration) extraRefused(k string) string {
+	if !strings.HasPrefix(k, extraPrefix) {
+		return ""
+	}
+	if file := strings.TrimPrefix(c.sources[k], "file:"); strings.HasPrefix(file, "http://") {
+		return fmt.Sprintf("refusing to write sysfs files from %q, fetched over plain HTTP", file)
+	}
+	return ""
+}
+
+// extraSettings returns the sysfs files from the [extra] table of c, in
+// lexical order of their paths.
+func (c *configuration) extraSettings() []setting.Setting {
+	var paths []string
+	for k := range c.settings {
+		if strings.HasPrefix(k, extraPrefix) {
+			paths = append(paths, strings.TrimPrefix(k, extraPrefix))
+		}
+	}
+	sort.Strings(paths)
+
+	extras := make([]setting.Setting, 0, len(paths))
+	for _, p := range paths {
+		extras = append(extras, extraSetting(p))
+	}
+	return extras
+}
@@ -0,0 +1,90 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// Permissions of the files ryzen-stabilizator writes. They are further
+// restricted by the umask, which can be set with -umask or self.umask.
+const (
+	// configFileMode is for files meant to be read by anyone, e.g. exported
+	// config files.
+	configFileMode = 0644
+	// privateFileMode is for files that may be sensitive, e.g. the cached
+	// copies of config files fetched from a URL, which may not be meant for
+	// everyone to read.
+	privateFileMode = 0600
+	// dirMode is for the directories created to hold those files.
+	dirMode = 0755
+)
+
+var (
+	// outputDir, if set, is the directory the files ryzen-stabilizator
+	// generates are written to instead, e.g. on a read-only root file
+	// system.
+	outputDir = ""
+)
+
+// outputPath returns where a generated file meant for path is written: path
+// itself, or the file with the same name in outputDir, if set.
+func outputPath(path string) string {
+	if outputDir == "" {
+		return path
+	}
+	return filepath.Join(outputDir, filepath.Base(path))
+}
+
+// explainWriteError returns err, from writing to the directory dir, explained
+// if the reason is a read-only file system, e.g. on immutable distributions,
+// which is otherwise not obvious. The explained error wraps err, so that the
+// path and the errno are kept.
+func explainWriteError(dir string, err error) error {
+	if errors.Is(err, syscall.EROFS) {
+		return fmt.Errorf("%w; %s is on a read-only file system, so remount it read-write, or write to another directory with -output-dir", err, dir)
+	}
+	return err
+}
+
+// writeFile writes data to a temporary file next to path, with the given
+// permissions, and renames it to path, so that an interrupted write does not
+// leave a truncated file behind, and an existing file does not keep looser
+// permissions than the ones requested. Failing because the file system is
+// read-only is explained as such.
+func writeFile(path string, data []byte, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return explainWriteError(filepath.Dir(path), err)
+	}
+	// TempFile creates it as 0600; the umask applies to perm, as it would
+	// on a new file.
+	mask := os.FileMode(currentUmask())
+	if err = tmp.Chmod(perm &^ mask); err == nil {
+		_, err = tmp.Write(data)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return explainWriteError(filepath.Dir(path), err)
+	}
+	return explainWriteError(filepath.Dir(path), os.Rename(tmp.Name(), path))
+}
@@ -0,0 +1,121 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// withUmask runs f with the given umask, restoring the previous one after.
+func withUmask(umask int, f func()) {
+	previous := syscall.Umask(umask)
+	defer syscall.Umask(previous)
+	f()
+}
+
+// checkMode fails t if path does not have the given permissions.
+func checkMode(t *testing.T, path string, want os.FileMode) {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.Mode().Perm(); got != want {
+		t.Errorf("%s has mode %04o, want %04o", filepath.Base(path), got, want)
+	}
+}
+
+func TestWriteFileMode(t *testing.T) {
+	tests := []struct {
+		perm  os.FileMode
+		umask int
+		want  os.FileMode
+	}{
+		{configFileMode, 022, 0644},
+		{configFileMode, 077, 0600},
+		{privateFileMode, 022, 0600},
+		{privateFileMode, 077, 0600},
+		{configFileMode, 027, 0640},
+	}
+	dir, err := ioutil.TempDir("", "files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for i, tt := range tests {
+		path := filepath.Join(dir, "file"+string(rune('a'+i)))
+		withUmask(tt.umask, func() {
+			if err := writeFile(path, []byte("data"), tt.perm); err != nil {
+				t.Fatal(err)
+			}
+		})
+		checkMode(t, path, tt.want)
+	}
+}
+
+func TestWriteFileTightensExisting(t *testing.T) {
+	dir, err := ioutil.TempDir("", "files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cached.toml")
+	if err := ioutil.WriteFile(path, []byte("old"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	// Make sure the umask did not restrict the existing file already.
+	if err := os.Chmod(path, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	withUmask(022, func() {
+		if err := writeFile(path, []byte("new"), privateFileMode); err != nil {
+			t.Fatal(err)
+		}
+	})
+	checkMode(t, path, privateFileMode)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "new" {
+		t.Errorf("contents are %q, want %q", data, "new")
+	}
+}
+
+func TestExplainWriteError(t *testing.T) {
+	readOnly := &os.PathError{Op: "open", Path: "/var/cache/ryzen-stabilizator/x.toml", Err: syscall.EROFS}
+	err := explainWriteError("/var/cache/ryzen-stabilizator", readOnly)
+	if !errors.Is(err, syscall.EROFS) {
+		t.Errorf("explainWriteError() = %v, which does not wrap EROFS", err)
+	}
+	var pathErr *os.PathError
+	if !errors.As(err, &pathErr) || pathErr.Path != readOnly.Path {
+		t.Errorf("explainWriteError() = %v, which does not keep the path", err)
+	}
+	if !strings.Contains(err.Error(), "/var/cache/ryzen-stabilizator is on a read-only file system") {
+		t.Errorf("explainWriteError() = %q, which does not name the directory", err)
+	}
+
+	other := &os.PathError{Op: "open", Path: "/x", Err: syscall.EACCES}
+	if err := explainWriteError("/", other); err != other {
+		t.Errorf("explainWriteError() = %v, expected the error unchanged", err)
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+const (
+	// postApplyKey is the config key holding the command to run after
+	// successfully applying the settings.
+	postApplyKey = "post_apply"
+
+	// hookEnvPrefix prefixes the environment variables describing the
+	// applied settings to the post-apply hook.
+	hookEnvPrefix = "RYZEN_STABILIZATOR_"
+)
+
+// hookResult is the outcome of running the post-apply hook.
+type hookResult struct {
+	Command    string `json:"command"`
+	ExitStatus int    `json:"exit_status"`
+	Output     string `json:"output,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// checkHookOwner makes sure the given config file of c is owned by root and
+// not writable by anyone else, since the hooks it specifies run as root. This
+// is what checkOwner found when the file was read, so that the file checked
+// is the one read, even if it was replaced since.
+func (c *configuration) checkHookOwner(file string) error {
+	// We cannot tell who controls a config file fetched from a URL.
+	if isURL(file) {
+		return fmt.Errorf("%q was fetched from a URL", file)
+	}
+	if file == stdinConfig {
+		return errors.New("the config was read from the standard input")
+	}
+	err, ok := c.untrusted[file]
+	if !ok {
+		return fmt.Errorf("%q was not read from the file system", file)
+	}
+	return err
+}
+
+// checkOwner makes sure f, opened from path, is owned by root and not writable
+// by group or others, and so are the directories it is in, following path and
+// then its symlinks, so that no one else can replace it either.
+func checkOwner(f *os.File, path string) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := checkRootOnly(path, info); err != nil {
+		return err
+	}
+	dirs := []string{filepath.Dir(path)}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil && filepath.Dir(resolved) != dirs[0] {
+		dirs = append(dirs, filepath.Dir(resolved))
+	}
+	for _, dir := range dirs {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return err
+		}
+		if err := checkRootOnly(dir, info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkRootOnly returns an error unless the file or directory described by
+// info, at path, is owned by root and not writable by group or others.
+func checkRootOnly(path string, info os.FileInfo) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return errors.New("unable to determine owner")
+	}
+	if stat.Uid != 0 {
+		return fmt.Errorf("%q is not owned by root", path)
+	}
+	if info.Mode().Perm()&0022 != 0 {
+		return fmt.Errorf("%q is writable by group or others", path)
+	}
+	return nil
+}
+
+// hookEnvironment returns the environment for the post-apply hook: ours, plus
+// the value and result of each applied setting, e.g.
+// RYZEN_STABILIZATOR_C6=disable and RYZEN_STABILIZATOR_C6_RESULT=changed.
+func hookEnvironment(report *applyReport) []string {
+	env := os.Environ()
+	var applied []string
+	for _, r := range report.Results {
+		name := hookEnvPrefix + strings.ToUpper(r.Setting)
+		env = append(env, name+"="+r.Value, name+"_RESULT="+r.Result)
+		applied = append(applied, r.Setting)
+	}
+	return append(env, hookEnvPrefix+"SETTINGS="+strings.Join(applied, " "))
+}
+
+// runPostApplyHook runs the post-apply hook from the configuration, if any,
+// provided every setting was applied successfully. The outcome is recorded
+// in report.
+func runPostApplyHook(cfg *configuration, report *applyReport) {
+	command, ok := cfg.settings.value(postApplyKey)
+	if !ok || command == "" {
+		return
+	}
+
+	if report.Summary.Failed > 0 {
+		if !jsonOutput {
+			fmt.Println("Post-apply hook: not running, as some settings failed to apply.")
+		}
+		return
+	}
+
+	result := &hookResult{Command: command, ExitStatus: -1}
+	report.Hook = result
+
+	file := strings.TrimPrefix(cfg.sources[postApplyKey], "file:")
+	if err := cfg.checkHookOwner(file); err != nil {
+		result.Error = fmt.Sprintf("refusing to run: %v", err)
+		if !jsonOutput {
+			fmt.Printf("Post-apply hook: %s.\n", result.Error)
+		}
+		return
+	}
+
+	cmd := exec.Command("/bin/sh", "-c", command)
+	cmd.Env = hookEnvironment(report)
+	output, err := cmd.CombinedOutput()
+	result.Output = string(output)
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		result.ExitStatus = 0
+	case errors.As(err, &exitErr):
+		result.ExitStatus = exitErr.ExitCode()
+	default:
+		result.Error = err.Error()
+	}
+
+	if jsonOutput {
+		return
+	}
+	fmt.Print(result.Output)
+	if result.Error != "" {
+		fmt.Printf("Post-apply hook: unable to run %q: %s.\n", command, result.Error)
+		return
+	}
+	if quietSuccess && result.ExitStatus == 0 {
+		return
+	}
+	fmt.Printf("Post-apply hook %q exited with status %d.\n", command, result.ExitStatus)
+}
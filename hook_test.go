@@ -0,0 +1,95 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ownerOf opens path and returns what checkOwner makes of it.
+func ownerOf(t *testing.T, path string) error {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	return checkOwner(f, path)
+}
+
+func TestCheckOwner(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("needs root, to own the files checked")
+	}
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Chmod(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "settings.toml")
+	if err := ioutil.WriteFile(path, []byte("c6 = \"disable\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ownerOf(t, path); err != nil {
+		t.Errorf("checkOwner() = %v for a file only root can write", err)
+	}
+
+	if err := os.Chmod(path, 0664); err != nil {
+		t.Fatal(err)
+	}
+	if err := ownerOf(t, path); err == nil {
+		t.Error("checkOwner() accepted a file writable by group")
+	}
+	if err := os.Chmod(path, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The file could then be replaced by rename.
+	if err := os.Chmod(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ownerOf(t, path); err == nil {
+		t.Error("checkOwner() accepted a file in a directory writable by others")
+	}
+	if err := os.Chmod(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// A symlink to a file in such a directory.
+	shared, err := ioutil.TempDir("", "shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(shared)
+	if err := os.Chmod(shared, 0777); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(shared, "settings.toml")
+	if err := ioutil.WriteFile(target, []byte("c6 = \"disable\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link.toml")
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+	if err := ownerOf(t, link); err == nil {
+		t.Error("checkOwner() accepted a symlink to a file in a directory writable by others")
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/klauspost/cpuid"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/cpuinfo"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/smu"
+)
+
+// cacheInfo has the cache sizes, in bytes, as reported by CPUID. Sizes that
+// could not be detected are -1.
+type cacheInfo struct {
+	L1I int `json:"l1i"`
+	L1D int `json:"l1d"`
+	L2  int `json:"l2"`
+	L3  int `json:"l3"`
+}
+
+// processorInfo describes the processor for -cpu-info.
+type processorInfo struct {
+	Vendor         string    `json:"vendor"`
+	Brand          string    `json:"brand"`
+	Family         int       `json:"family"`
+	Model          int       `json:"model"`
+	Stepping       *int      `json:"stepping,omitempty"`
+	PhysicalCores  int       `json:"physical_cores"`
+	ThreadsPerCore int       `json:"threads_per_core"`
+	LogicalCores   int       `json:"logical_cores"`
+	CacheLine      int       `json:"cache_line"`
+	Cache          cacheInfo `json:"cache"`
+	Features       []string  `json:"features"`
+	Microcode      []string  `json:"microcode,omitempty"`
+	CCDs           int       `json:"ccds,omitempty"`
+	CoresPerCCD    int       `json:"cores_per_ccd,omitempty"`
+	L3Complexes    []string  `json:"l3_complexes,omitempty"`
+	L3Allocation   string    `json:"l3_allocation"`
+}
+
+// gatherProcessorInfo collects the processor information, mostly from CPUID.
+func gatherProcessorInfo() processorInfo {
+	info := processorInfo{
+		Vendor:         cpuid.CPU.VendorString,
+		Brand:          strings.TrimSpace(cpuid.CPU.BrandName),
+		Family:         cpuinfo.DetectedFamily(),
+		Model:          cpuid.CPU.Model,
+		PhysicalCores:  cpuid.CPU.PhysicalCores,
+		ThreadsPerCore: cpuid.CPU.ThreadsPerCore,
+		LogicalCores:   cpuid.CPU.LogicalCores,
+		CacheLine:      cpuid.CPU.CacheLine,
+		Cache: cacheInfo{
+			L1I: cpuid.CPU.Cache.L1I,
+			L1D: cpuid.CPU.Cache.L1D,
+			L2:  cpuid.CPU.Cache.L2,
+			L3:  cpuid.CPU.Cache.L3,
+		},
+		Features: cpuid.CPU.Features.Strings(),
+	}
+	// CPUID, as exposed by the library, has no stepping, so we take it from
+	// /proc/cpuinfo instead.
+	if stepping, err := cpuinfo.Stepping(); err == nil {
+		info.Stepping = &stepping
+	}
+	// The CCDs are only known through the SMU.
+	if smu.Available() {
+		if ccds, err := smu.PresentCCDs(); err == nil && len(ccds) > 0 {
+			info.CCDs = len(ccds)
+			info.CoresPerCCD = info.PhysicalCores / len(ccds)
+		}
+	}
+	if complexes, err := cpuinfo.L3Complexes(); err == nil {
+		info.L3Complexes = complexes
+	}
+	info.L3Allocation = l3AllocationDescription()
+	// Normally there is a single microcode revision, but we list them all,
+	// as cores running different ones are worth knowing about.
+	if cpuinfo.MicrocodeAvailable() {
+		if revisions, _, err := cpuinfo.MicrocodeRevisions(); err == nil {
+			for _, r := range revisions {
+				info.Microcode = append(info.Microcode, fmt.Sprintf("0x%x", r))
+			}
+		}
+	}
+	return info
+}
+
+// l3AllocationDescription describes the support for L3 cache allocation, and
+// how to use it, if available.
+func l3AllocationDescription() string {
+	allocation, err := cpuinfo.L3Allocation()
+	switch {
+	case err != nil:
+		return fmt.Sprintf("unknown (%v)", err)
+	case !allocation.Supported:
+		return "unsupported"
+	case !allocation.Mounted:
+		return "supported; mount resctrl on /sys/fs/resctrl to use it"
+	}
+	return fmt.Sprintf("supported, through /sys/fs/resctrl; %d classes of service, %d ways", allocation.Classes, allocation.Ways)
+}
+
+// cacheSize formats a cache size in bytes for display.
+func cacheSize(size int) string {
+	switch {
+	case size < 0:
+		return "unknown"
+	case size >= 1024*1024 && size%(1024*1024) == 0:
+		return fmt.Sprintf("%d MiB", size/(1024*1024))
+	}
+	return fmt.Sprintf("%d KiB", size/1024)
+}
+
+// showProcessorInfo displays the processor information usually needed for
+// bug reports, as text or JSON.
+func showProcessorInfo() {
+	info := gatherProcessorInfo()
+
+	if jsonOutput {
+		buf, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			fmt.Printf("Error: unable to produce JSON output: %v.\n", err)
+			return
+		}
+		fmt.Println(string(buf))
+		return
+	}
+
+	stepping := "unknown"
+	if info.Stepping != nil {
+		stepping = fmt.Sprintf("%d", *info.Stepping)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Vendor:\t%s\n", info.Vendor)
+	fmt.Fprintf(w, "Brand:\t%s\n", info.Brand)
+	fmt.Fprintf(w, "Family:\t%d (%Xh)\n", info.Family, info.Family)
+	fmt.Fprintf(w, "Model:\t%d (%Xh)\n", info.Model, info.Model)
+	fmt.Fprintf(w, "Stepping:\t%s\n", stepping)
+	microcode := "unknown"
+	if len(info.Microcode) > 0 {
+		microcode = strings.Join(info.Microcode, ", ")
+	}
+	fmt.Fprintf(w, "Microcode:\t%s\n", microcode)
+	fmt.Fprintf(w, "Physical cores:\t%d\n", info.PhysicalCores)
+	fmt.Fprintf(w, "Threads per core:\t%d\n", info.ThreadsPerCore)
+	fmt.Fprintf(w, "Logical cores:\t%d\n", info.LogicalCores)
+	ccds := "unknown"
+	if info.CCDs > 0 {
+		ccds = fmt.Sprintf("%d (%d cores each)", info.CCDs, info.CoresPerCCD)
+	}
+	fmt.Fprintf(w, "CCDs:\t%s\n", ccds)
+	if len(info.L3Complexes) > 0 {
+		fmt.Fprintf(w, "L3 complexes (CCX):\t%d (CPUs %s)\n", len(info.L3Complexes), strings.Join(info.L3Complexes, "; "))
+	}
+	fmt.Fprintf(w, "Cache line:\t%d bytes\n", info.CacheLine)
+	fmt.Fprintf(w, "L1 instruction cache:\t%s\n", cacheSize(info.Cache.L1I))
+	fmt.Fprintf(w, "L1 data cache:\t%s\n", cacheSize(info.Cache.L1D))
+	fmt.Fprintf(w, "L2 cache:\t%s\n", cacheSize(info.Cache.L2))
+	fmt.Fprintf(w, "L3 cache:\t%s\n", cacheSize(info.Cache.L3))
+	fmt.Fprintf(w, "L3 cache allocation:\t%s\n", info.L3Allocation)
+	fmt.Fprintf(w, "Features:\t%s\n", strings.Join(info.Features, " "))
+	w.Flush()
+}
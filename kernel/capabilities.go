@@ -0,0 +1,84 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kernel
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// Capability is a Linux capability, see capabilities(7).
+type Capability uint
+
+// Capabilities ryzen-stabilizator may need.
+const (
+	// CapDACOverride allows writing files regardless of their permissions,
+	// e.g. the sysfs files, which belong to root.
+	CapDACOverride Capability = 1
+	// CapSysModule allows loading kernel modules, e.g. msr.
+	CapSysModule Capability = 16
+	// CapSysRawIO allows accessing the MSRs, through /dev/cpu/*/msr.
+	CapSysRawIO Capability = 17
+)
+
+var (
+	// ErrSysctlRoot is returned, wrapped, when writing to a sysctl file, in
+	// /proc/sys, is denied without being root. Unlike other files, sysctl
+	// files check the user, not CAP_DAC_OVERRIDE, so capabilities are not
+	// enough.
+	ErrSysctlRoot = errors.New("changing sysctl settings needs root, capabilities are not enough")
+)
+
+// EffectiveCapabilities returns the effective capabilities of the current
+// process, as a bit set of capabilities, from /proc/self/status.
+func EffectiveCapabilities() (uint64, error) {
+	value, err := processStatus("CapEff")
+	if err != nil {
+		return 0, err
+	}
+	caps, err := strconv.ParseUint(value, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid CapEff in %s: %v", procStatusFile, err)
+	}
+	return caps, nil
+}
+
+// HasCapabilities returns whether the current process has every one of the
+// given capabilities. If they cannot be found out, it falls back to whether
+// the process runs as root.
+func HasCapabilities(caps ...Capability) bool {
+	effective, err := EffectiveCapabilities()
+	if err != nil {
+		return os.Geteuid() == 0
+	}
+	for _, c := range caps {
+		if effective&(1<<c) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// SysctlWriteError explains err, from writing to a sysctl file, if it was
+// denied because we are not root, wrapping it in ErrSysctlRoot. Other errors
+// are returned unchanged.
+func SysctlWriteError(err error) error {
+	if os.Geteuid() != 0 && (errors.Is(err, syscall.EACCES) || errors.Is(err, syscall.EPERM)) {
+		return fmt.Errorf("%w (%v)", ErrSysctlRoot, err)
+	}
+	return err
+}
@@ -0,0 +1,103 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kernel
+
+import (
+	"io/ioutil"
+	"strings"
+)
+
+const (
+	cmdlineFile = "/proc/cmdline"
+)
+
+// Parameter is a kernel command line parameter, e.g. idle=poll, or nosmt,
+// which has no value.
+type Parameter struct {
+	Name  string
+	Value string
+}
+
+// String returns the parameter the way it is written in the command line.
+func (p Parameter) String() string {
+	if p.Value == "" {
+		return p.Name
+	}
+	return p.Name + "=" + p.Value
+}
+
+// normalizeParameterName returns name with dashes replaced by underscores, as
+// the kernel considers them the same in parameter names.
+func normalizeParameterName(name string) string {
+	return strings.Replace(name, "-", "_", -1)
+}
+
+// ParseCommandLine splits a kernel command line into its parameters. Values
+// may be double-quoted to include spaces, as the kernel allows, and names are
+// returned with underscores instead of dashes. Anything after "--" is for
+// init, so it is ignored.
+func ParseCommandLine(cmdline string) []Parameter {
+	var params []Parameter
+	var field strings.Builder
+	quoted := false
+	flush := func() {
+		if field.Len() == 0 {
+			return
+		}
+		name, value := field.String(), ""
+		if i := strings.Index(name, "="); i >= 0 {
+			name, value = name[:i], name[i+1:]
+		}
+		params = append(params, Parameter{normalizeParameterName(name), value})
+		field.Reset()
+	}
+	for _, f := range strings.Fields(cmdline) {
+		if !quoted && f == "--" {
+			break
+		}
+		if field.Len() > 0 {
+			field.WriteByte(' ')
+		}
+		quoted = quoted != (strings.Count(f, `"`)%2 == 1)
+		field.WriteString(strings.Replace(f, `"`, "", -1))
+		if !quoted {
+			flush()
+		}
+	}
+	flush()
+	return params
+}
+
+// CommandLine returns the parameters of the running kernel command line.
+func CommandLine() ([]Parameter, error) {
+	cmdline, err := ioutil.ReadFile(cmdlineFile)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCommandLine(string(cmdline)), nil
+}
+
+// Lookup returns the last of the given parameters with the provided name, in
+// either spelling, as that is the one the kernel uses, and whether there is
+// one at all.
+func Lookup(params []Parameter, name string) (Parameter, bool) {
+	name = normalizeParameterName(name)
+	found, ok := Parameter{}, false
+	for _, p := range params {
+		if p.Name == name {
+			found, ok = p, true
+		}
+	}
+	return found, ok
+}
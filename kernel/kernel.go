@@ -0,0 +1,119 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kernel
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+const (
+	osReleaseFile = "/proc/sys/kernel/osrelease"
+)
+
+// Version is a kernel version, e.g. 6.1.0-rc2. Anything after the patch
+// level other than a release candidate, e.g. a fourth number or a
+// distribution suffix, is ignored.
+type Version struct {
+	Major, Minor, Patch int
+	// RC is the release candidate number, or 0 for a final release.
+	RC int
+}
+
+// ParseVersion parses a kernel version such as "6.1", "6.1.0-rc2",
+// "5.15.0-91-generic" or "5.15.153.1-microsoft-standard-WSL2".
+func ParseVersion(s string) (Version, error) {
+	var v Version
+	s = strings.TrimSpace(s)
+	base, suffix := s, ""
+	if i := strings.IndexAny(s, "-+_ "); i >= 0 {
+		base, suffix = s[:i], s[i+1:]
+	}
+
+	parts := strings.Split(base, ".")
+	if len(parts) < 2 {
+		return v, fmt.Errorf("invalid kernel version %q", s)
+	}
+	numbers := []*int{&v.Major, &v.Minor, &v.Patch}
+	if len(parts) > len(numbers) {
+		parts = parts[:len(numbers)]
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid kernel version %q", s)
+		}
+		*numbers[i] = n
+	}
+
+	if strings.HasPrefix(suffix, "rc") {
+		rc := strings.TrimPrefix(suffix, "rc")
+		if i := strings.IndexAny(rc, "-+_ "); i >= 0 {
+			rc = rc[:i]
+		}
+		n, err := strconv.Atoi(rc)
+		if err != nil || n <= 0 {
+			return v, fmt.Errorf("invalid release candidate in kernel version %q", s)
+		}
+		v.RC = n
+	}
+	return v, nil
+}
+
+// Compare returns -1, 0 or 1 depending on whether v is older than, the same
+// as, or newer than o, respectively. A release candidate is older than the
+// final release.
+func (v Version) Compare(o Version) int {
+	a := []int{v.Major, v.Minor, v.Patch}
+	b := []int{o.Major, o.Minor, o.Patch}
+	for i := range a {
+		switch {
+		case a[i] < b[i]:
+			return -1
+		case a[i] > b[i]:
+			return 1
+		}
+	}
+	switch {
+	case v.RC == o.RC:
+		return 0
+	case v.RC == 0:
+		return 1
+	case o.RC == 0:
+		return -1
+	case v.RC < o.RC:
+		return -1
+	}
+	return 1
+}
+
+// String returns the version in the usual format, e.g. 6.1.0-rc2.
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.RC > 0 {
+		s += fmt.Sprintf("-rc%d", v.RC)
+	}
+	return s
+}
+
+// Running returns the version of the running kernel.
+func Running() (Version, error) {
+	release, err := ioutil.ReadFile(osReleaseFile)
+	if err != nil {
+		return Version{}, err
+	}
+	return ParseVersion(string(release))
+}
@@ -0,0 +1,81 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kernel
+
+import "testing"
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Version
+	}{
+		{"6.1", Version{Major: 6, Minor: 1}},
+		{"6.1.0", Version{Major: 6, Minor: 1}},
+		{"6.1.0-rc2", Version{Major: 6, Minor: 1, RC: 2}},
+		{"6.8-rc7", Version{Major: 6, Minor: 8, RC: 7}},
+		{"5.15.0-91-generic", Version{Major: 5, Minor: 15}},
+		{"6.6.13-200.fc39.x86_64", Version{Major: 6, Minor: 6, Patch: 13}},
+		{"6.7.0-rc1+", Version{Major: 6, Minor: 7, RC: 1}},
+		{"5.15.153.1-microsoft-standard-WSL2", Version{Major: 5, Minor: 15, Patch: 153}},
+		{"4.19.112.2.3", Version{Major: 4, Minor: 19, Patch: 112}},
+		{" 6.1.0\n", Version{Major: 6, Minor: 1}},
+	}
+	for _, tt := range tests {
+		got, err := ParseVersion(tt.s)
+		if err != nil {
+			t.Errorf("ParseVersion(%q) failed: %v", tt.s, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseVersion(%q) = %+v, want %+v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestParseVersionInvalid(t *testing.T) {
+	for _, s := range []string{"", "6", "six.one", "6.x", "6.1.-1", "6.1.0-rc", "6.1.0-rc0", "6.1.0-rcx"} {
+		if v, err := ParseVersion(s); err == nil {
+			t.Errorf("ParseVersion(%q) = %+v, want an error", s, v)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"6.1", "6.1.0", 0},
+		{"6.1.0-rc2", "6.1", -1},
+		{"6.1", "6.1.0-rc2", 1},
+		{"6.1.0-rc2", "6.1.0-rc3", -1},
+		{"6.1.1", "6.1.0", 1},
+		{"5.15.153.1-microsoft-standard-WSL2", "5.15", 1},
+		{"5.15", "6.1", -1},
+		{"6.10", "6.9", 1},
+	}
+	for _, tt := range tests {
+		a, err := ParseVersion(tt.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ParseVersion(tt.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := a.Compare(b); got != tt.want {
+			t.Errorf("%s compared to %s = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
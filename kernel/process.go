@@ -0,0 +1,63 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kernel
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	procStatusFile = "/proc/self/status"
+)
+
+// processStatus returns the value of the given field, e.g. CapEff, of
+// /proc/self/status.
+func processStatus(field string) (string, error) {
+	f, err := os.Open(procStatusFile)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, field+":") {
+			return strings.TrimSpace(strings.TrimPrefix(line, field+":")), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("no %s in %s", field, procStatusFile)
+}
+
+// Umask returns the umask of the current process, from /proc/self/status,
+// which has it since Linux 4.7. Unlike with umask(2), reading it does not
+// change it, even briefly, so it is safe while other goroutines create files.
+func Umask() (int, error) {
+	value, err := processStatus("Umask")
+	if err != nil {
+		return 0, err
+	}
+	umask, err := strconv.ParseUint(value, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid Umask in %s: %v", procStatusFile, err)
+	}
+	return int(umask), nil
+}
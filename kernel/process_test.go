@@ -0,0 +1,36 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kernel
+
+import (
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestUmask(t *testing.T) {
+	if _, err := os.Stat(procStatusFile); err != nil {
+		t.Skip(err)
+	}
+	previous := syscall.Umask(027)
+	defer syscall.Umask(previous)
+
+	umask, err := Umask()
+	if err != nil {
+		t.Skipf("no umask in %s, as before Linux 4.7: %v", procStatusFile, err)
+	}
+	if umask != 027 {
+		t.Errorf("Umask() = %#o, want 027", umask)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/setting"
+)
+
+// settingInfo describes a setting for -list-settings.
+type settingInfo struct {
+	Key         string   `json:"key"`
+	Description string   `json:"description"`
+	Values      []string `json:"values"`
+	Persistence string   `json:"persistence"`
+	Supported   bool     `json:"supported"`
+	Reason      string   `json:"reason,omitempty"`
+}
+
+// listSettings displays every setting ryzen-stabilizator can manage, along
+// with its config key, accepted values and whether it is supported on this
+// machine.
+func listSettings() {
+	var infos []settingInfo
+	for _, s := range setting.All() {
+		info := settingInfo{
+			Key:         s.Name(),
+			Description: s.Description(),
+			Values:      s.Values(),
+			Persistence: setting.Persistence(s),
+			Supported:   true,
+		}
+		if err := s.Available(); err != nil {
+			info.Supported = false
+			info.Reason = err.Error()
+		}
+		infos = append(infos, info)
+	}
+
+	if jsonOutput {
+		buf, err := json.MarshalIndent(infos, "", "  ")
+		if err != nil {
+			fmt.Printf("Error: unable to produce JSON output: %v.\n", err)
+			return
+		}
+		fmt.Println(string(buf))
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tDESCRIPTION\tVALUES\tPERSISTENCE\tSUPPORTED")
+	for _, info := range infos {
+		supported := "yes"
+		if !info.Supported {
+			supported = fmt.Sprintf("no (%s)", info.Reason)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", info.Key, info.Description, strings.Join(info.Values, ", "), info.Persistence, supported)
+	}
+	w.Flush()
+
+	// Whether a setting sticks also depends on what else is running.
+	for _, line := range powerDaemonsStatus() {
+		fmt.Printf("\n%s\n", line)
+	}
+}
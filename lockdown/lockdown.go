@@ -0,0 +1,71 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lockdown
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+const (
+	lockdownControlFile = "/sys/kernel/security/lockdown"
+
+	// None indicates the kernel is not locked down.
+	None = "none"
+	// Integrity is the lockdown mode that blocks modifications to the running
+	// kernel, which includes writes to MSRs.
+	Integrity = "integrity"
+	// Confidentiality is the strictest lockdown mode; it also blocks writes to
+	// MSRs.
+	Confidentiality = "confidentiality"
+)
+
+// Mode returns the kernel lockdown mode currently in effect. The control file
+// lists every mode supported, with the active one in brackets, e.g.
+// "none [integrity] confidentiality". Kernels without the lockdown LSM do not
+// have the control file, in which case we report None.
+func Mode() (string, error) {
+	value, err := ioutil.ReadFile(lockdownControlFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return None, nil
+		}
+		return "", err
+	}
+
+	for _, mode := range strings.Fields(string(value)) {
+		if strings.HasPrefix(mode, "[") && strings.HasSuffix(mode, "]") {
+			return strings.Trim(mode, "[]"), nil
+		}
+	}
+	return "", fmt.Errorf("unable to parse lockdown mode from %q", strings.TrimSpace(string(value)))
+}
+
+// CheckMSRWrites returns an error explaining that writes to MSRs will be
+// denied, if the kernel is locked down in either integrity or confidentiality
+// mode. Reading MSRs is still allowed under lockdown.
+func CheckMSRWrites() error {
+	mode, err := Mode()
+	if err != nil {
+		// If we cannot tell the mode, let the write itself report any problem.
+		return nil
+	}
+	switch mode {
+	case Integrity, Confidentiality:
+		return fmt.Errorf("kernel lockdown is in %s mode (secure boot?), so writes to MSRs will be denied", mode)
+	}
+	return nil
+}
@@ -14,18 +14,31 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"os"
+	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
+	"text/template"
+	"time"
 
-	"github.com/BurntSushi/toml"
 	"github.com/klauspost/cpuid"
-	"github.com/qrwteyrutiyoup/ryzen-stabilizator/aslr"
-	"github.com/qrwteyrutiyoup/ryzen-stabilizator/boosting"
-	"github.com/qrwteyrutiyoup/ryzen-stabilizator/c6"
+	_ "github.com/qrwteyrutiyoup/ryzen-stabilizator/aslr"
+	_ "github.com/qrwteyrutiyoup/ryzen-stabilizator/cpuidle"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/cpuinfo"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/cpulist"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/kernel"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/msr"
+	_ "github.com/qrwteyrutiyoup/ryzen-stabilizator/onlinecores"
+	_ "github.com/qrwteyrutiyoup/ryzen-stabilizator/prefetch"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/setting"
+	_ "github.com/qrwteyrutiyoup/ryzen-stabilizator/smt"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/smu"
+	_ "github.com/qrwteyrutiyoup/ryzen-stabilizator/thp"
+	_ "github.com/qrwteyrutiyoup/ryzen-stabilizator/watchdog"
 )
 
 const (
@@ -38,22 +51,37 @@ const (
 
 var (
 	version = "unspecified/git version"
-)
 
-// rsSettings contains definitions for C6 C-state, processor boosting, address
-// space layout randomization (ASLR) and power supply idle control workaround
-// (PSIC Workaround). All these parameters are "string" and accept as values
-// `enabled' and `disabled'.
-type rsSettings struct {
-	C6             string `toml:"c6"`
-	Boosting       string `toml:"boosting"`
-	ASLR           string `toml:"aslr"`
-	PSICWorkaround string `toml:"psicworkaround"`
-}
+	// jsonOutput indicates whether we should produce JSON instead of the
+	// human-readable output.
+	jsonOutput = false
+
+	// verbose indicates whether we should display additional details, such
+	// as where each applied value came from.
+	verbose = false
+
+	// noStatus indicates whether the status should be left out after
+	// applying, e.g. in scripts that only care about the outcome.
+	noStatus = false
+
+	// strictRoot indicates whether running as root is required, instead of
+	// only having the requiredCapabilities.
+	strictRoot = false
+
+	// requiredCapabilities are the ones needed to change settings without
+	// being root: accessing the MSRs, and writing to the sysfs files, which
+	// belong to root. The sysctl settings, in /proc/sys, still need root.
+	requiredCapabilities = []kernel.Capability{kernel.CapSysRawIO, kernel.CapDACOverride}
+
+	// skipFamilyCheck indicates whether a processor family other than Zen
+	// should only be warned about, instead of refusing to run.
+	skipFamilyCheck = false
+)
 
 // sanityCheck performs a few checks to be sure we should be running this
-// program.
-func sanityCheck() error {
+// program. Being root is only required if we are going to change something,
+// as the status of most settings can be read by anyone.
+func sanityCheck(needRoot bool) error {
 	switch {
 	// Check if we are running Linux.
 	case runtime.GOOS != "linux":
@@ -61,296 +89,571 @@ func sanityCheck() error {
 	// Check if we are running on an AMD processor.
 	case cpuid.CPU.VendorID != cpuid.AMD:
 		return fmt.Errorf("this is not an AMD processor")
-	// Check if it is the right family, 17h (Zen).
-	case cpuid.CPU.Family != amdZenFamily:
-		return fmt.Errorf("wrong family of AMD processors; expected 23 (17h), got %d", cpuid.CPU.Family)
-	// Check if we are running as root.
-	case os.Geteuid() != 0:
+	// Check if it is the right family, 17h (Zen), unless asked to only
+	// warn about it, e.g. on a processor newer than this program. The
+	// detected family is checked; an assumed one only gates features.
+	case cpuinfo.DetectedFamily() != amdZenFamily && !skipFamilyCheck:
+		return fmt.Errorf("wrong family of AMD processors; expected 23 (17h), got %d", cpuinfo.DetectedFamily())
+	// Check if we are running as root, or, unless strictRoot is set, with
+	// the capabilities needed to write to the MSRs and the sysfs files.
+	// Root is accepted even without them, e.g. in a container, where the
+	// settings that need a missing one fail on their own. The simulated
+	// MSRs need no privileges.
+	case needRoot && !cpuinfo.Simulated && strictRoot && os.Geteuid() != 0:
 		return fmt.Errorf("you need to be root to use this program")
+	case needRoot && !cpuinfo.Simulated && os.Geteuid() != 0 && !kernel.HasCapabilities(requiredCapabilities...):
+		return fmt.Errorf("you need to be root, or to have the CAP_SYS_RAWIO and CAP_DAC_OVERRIDE capabilities, to use this program")
+	}
+	// The warning goes to the standard error, so that it does not break
+	// the output of -format json, nagios or prometheus.
+	if cpuinfo.DetectedFamily() != amdZenFamily {
+		fmt.Fprintf(os.Stderr, "Warning: wrong family of AMD processors; expected 23 (17h), got %d. Continuing, as asked by -skip-family-check or -assume-family.\n", cpuinfo.DetectedFamily())
 	}
 	return nil
 }
 
-// disablePSICWorkaround disables Power Supply Idle Control workaround.
-func disablePSICWorkaround() {
-	if !c6.Available() {
-		fmt.Println("Power Supply Idle Control workaround unavailable - check if msr module loaded.")
-		return
+// capitalize returns s with its first letter in upper case, so that setting
+// descriptions can start a sentence.
+func capitalize(s string) string {
+	if s == "" {
+		return s
 	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
 
-	fmt.Printf("Disabling Power Supply Idle Control Workaround:   ")
-	err := c6.PackageEnable()
-	if err != nil {
-		fmt.Printf("oops: %v\n", err)
-		return
+// actionDescription describes what applying value to a setting means, e.g.
+// "Enabling C6 C-state".
+func actionDescription(s setting.Setting, value string) string {
+	switch setting.Normalize(s, value) {
+	case setting.Enabled:
+		return fmt.Sprintf("Enabling %s", s.Description())
+	case setting.Disabled:
+		return fmt.Sprintf("Disabling %s", s.Description())
 	}
-	fmt.Println("SUCCESS")
+	return fmt.Sprintf("Setting %s to %q", s.Description(), value)
 }
 
-// enablePSICWorkaround enables Power Supply Idle Control workaround.
-func enablePSICWorkaround() {
-	if !c6.Available() {
-		fmt.Println("Power Supply Idle Control workaround unavailable - check if msr module loaded.")
-		return
+// logProvenance displays, in verbose mode, the value a setting resolved to and
+// where that value came from.
+func logProvenance(name, value, source string) {
+	if verbose && !jsonOutput {
+		fmt.Printf("%s = %q (%s)\n", name, value, source)
 	}
+}
 
-	fmt.Printf("Enabling Power Supply Idle Control workaround:   ")
-	err := c6.PackageDisable()
-	if err != nil {
-		fmt.Printf("oops: %v\n", err)
-		return
+// handleConfiguration applies the settings from the provided configuration
+// that the filter allows, recording the outcome in report. Available settings
+// the configuration does not mention, or that are filtered out, are reported
+// as left unchanged, so that the scope of what ran is clear.
+func handleConfiguration(cfg *configuration, filter *settingFilter, report *applyReport) {
+	// Settings meant for a newer kernel may not work as expected.
+	tooOld := cfg.kernelTooOld()
+	// The sysfs files from the [extra] table come last.
+	for _, s := range append(setting.All(), cfg.extraSettings()...) {
+		value, ok := cfg.settings.value(s.Name())
+		if !ok {
+			if !jsonOutput && !quietSuccess && s.Available() == nil {
+				fmt.Printf("%s: not specified, leaving unchanged\n", s.Name())
+			}
+			continue
+		}
+		if !filter.allows(s.Name()) {
+			if !jsonOutput && !quietSuccess {
+				fmt.Printf("%s: filtered out, leaving unchanged\n", s.Name())
+			}
+			continue
+		}
+		if tooOld != "" {
+			report.skip(s, value, tooOld)
+			continue
+		}
+		if refused := cfg.extraRefused(s.Name()); refused != "" {
+			report.skip(s, value, refused)
+			continue
+		}
+		if conflict := cfg.governorConflict(); conflict != "" && s.Name() == "governor" {
+			report.skip(s, value, conflict)
+			continue
+		}
+		logProvenance(s.Name(), value, cfg.sources[s.Name()])
+		report.apply(s, value)
 	}
-	fmt.Println("SUCCESS")
 }
 
-// disableC6 disables C6 C-state.
-func disableC6() {
-	if !c6.Available() {
-		fmt.Println("C6 C-state control unavailable - check if msr module loaded.")
+// finish reports the outcome of the apply, followed, unless noStatus is set,
+// by the current status of the registered settings, compared to the
+// configured one if cfg is not nil.
+func finish(report *applyReport, cfg *configuration) {
+	if jsonOutput {
+		out := struct {
+			*applyReport
+			Status []settingStatus `json:"status,omitempty"`
+		}{applyReport: report}
+		if !noStatus {
+			out.Status = statusEntries(cfg)
+		}
+		buf, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			fmt.Printf("Error: unable to produce JSON output: %v.\n", err)
+			return
+		}
+		fmt.Println(string(buf))
 		return
 	}
 
-	fmt.Printf("Disabling C6 C-state:   ")
-	err := c6.Disable()
-	if err != nil {
-		fmt.Printf("oops: %v\n", err)
-		return
+	if quietSuccess {
+		if !report.eventful() {
+			fmt.Printf("%s.\n", report.Summary)
+			return
+		}
+		// Something happened, so we report in detail, as we would have done
+		// while applying.
+		printBanner()
+		for _, r := range report.Results {
+			fmt.Println(resultLine(r))
+		}
 	}
-	fmt.Println("SUCCESS")
-}
 
-// enableC6 enables C6 C-state.
-func enableC6() {
-	if !c6.Available() {
-		fmt.Println("C6 C-state control unavailable - check if msr module loaded.")
-		return
+	if len(report.Results) > 0 {
+		fmt.Printf("%s.\n", report.Summary)
+		fmt.Printf("Applying took %.1f ms.\n", report.DurationMs)
 	}
-
-	fmt.Printf("Enabling C6 C-state:   ")
-	err := c6.Enable()
-	if err != nil {
-		fmt.Printf("oops: %v\n", err)
-		return
+	if !noStatus {
+		showStatus(cfg)
 	}
-	fmt.Println("SUCCESS")
 }
 
-// disableBoosting disables processor boosting.
-func disableBoosting() {
-	if !boosting.Available() {
-		fmt.Println("Processor boosting unavailable - check if AMD Cool'n'Quiet enabled and cpufreq module loaded.")
-		return
+// printBanner displays the program name, version and copyright.
+func printBanner() {
+	fmt.Printf("%s %s\n%s\n\n", program, version, copyright)
+}
+
+func main() {
+	configFilePtr := flag.String("config", "", "ryzen-stabilizator config file, an HTTP(S) URL to fetch it from, or - to read it from the standard input")
+	configFormatPtr := flag.String("config-format", "", "Format of the config file, toml or yaml, instead of the one implied by its extension (toml for the standard input)")
+	flag.DurationVar(&configFetchTimeout, "config-timeout", configFetchTimeout, "Timeout for fetching the config file from a URL")
+	flag.BoolVar(&configInsecure, "config-insecure", false, "Do not verify the TLS certificate when fetching the config file from a HTTPS URL")
+	flag.StringVar(&configCacheDir, "config-cache-dir", configCacheDir, "Directory caching the config files fetched from URLs, used if fetching fails")
+	onlyPtr := flag.String("only", "", "Comma-separated list of settings from the config to apply, ignoring the others")
+	skipPtr := flag.String("skip", "", "Comma-separated list of settings from the config not to apply")
+	configDirPtr := flag.String("config-dir", "", "Directory with ryzen-stabilizator config files (*.toml, *.yaml and *.yml), applied in lexical order")
+	enablePSICWorkaroundPtr := flag.Bool("enable-psicworkaround", false, "Enable Power Supply Idle Control Workaround")
+	disablePSICWorkaroundPtr := flag.Bool("disable-psicworkaround", false, "Disable Power Supply Idle Control Workaround")
+	enableC6Ptr := flag.Bool("enable-c6", false, "Enable C6 C-state")
+	disableC6Ptr := flag.Bool("disable-c6", false, "Disable C6 C-state")
+	enableBoostingPtr := flag.Bool("enable-boosting", false, "Enable processor boosting")
+	disableBoostingPtr := flag.Bool("disable-boosting", false, "Disable processor boosting")
+	enableASLRPtr := flag.Bool("enable-aslr", false, "Enable address space layout randomization (ASLR)")
+	disableASLRPtr := flag.Bool("disable-aslr", false, "Disable address space layout randomization (ASLR)")
+	flag.IntVar(&statusWorkers, "parallel-status", statusWorkers, "How many status reads run at once; 1 reads them one at a time")
+	jsonSchemaPtr := flag.Bool("json-schema", false, "Display a JSON Schema of the config file, for editors and validators")
+	configCheckPtr := flag.Bool("config-check", false, "Validate the config file, reporting every problem, without applying it")
+	configParsePtr := flag.Bool("config-test-only-parse", false, "Only check that the config file parses and has no unknown keys, without probing the hardware")
+	diffExitCodePtr := flag.Bool("diff-exit-code", false, "Compare current state to the config file without changing anything, exiting with 2 if it differs, or 1 if it could not be checked")
+	nagiosPtr := flag.Bool("nagios", false, "Compare current state to the config file and exit with a Nagios plugin status, like -format=nagios")
+	flag.BoolVar(&jsonOutput, "json", false, "Produce JSON output, like -format=json")
+	formatPtr := flag.String("format", "", "Output format: text, json, nagios (like -nagios) or prometheus, which only reports the status")
+	flag.BoolVar(&quietSuccess, "quiet-success", false, "Print a single line if every setting was already set, and details only if something changed or failed")
+	flag.BoolVar(&noStatus, "no-status", false, "Do not display the status after applying, only the outcome")
+	flag.BoolVar(&strictRoot, "strict-root", false, "Require running as root, instead of accepting the capabilities needed, e.g. CAP_SYS_RAWIO")
+	flag.BoolVar(&verbose, "verbose", false, "Display additional details, such as where each applied value came from")
+	flag.BoolVar(&skipFamilyCheck, "skip-family-check", false, "Only warn if the processor is not of the Zen family, instead of refusing to run, e.g. on a newer family")
+	assumeFamilyPtr := flag.String("assume-family", "", "Assume the given processor family, e.g. 0x17, for feature gating instead of the detected one")
+	exportConfigPtr := flag.String("export-config", "", "Write a config file reproducing the current state to the given path")
+	flag.StringVar(&outputDir, "output-dir", "", "Write generated files, e.g. from -export-config, to this directory instead, and cache remote config files in it")
+	dumpConfigDefaultsPtr := flag.String("dump-config-defaults", "", "Write a commented config file template with every setting and option to the given path, to start a config from")
+	listSettingsPtr := flag.Bool("list-settings", false, "List every setting that can be managed and whether it is supported")
+	cpuInfoPtr := flag.Bool("cpu-info", false, "Display processor information useful for bug reports")
+	nicePtr := flag.String("nice", "", "Run with the given nice value (-20 to 19), overriding self.nice from the config")
+	umaskPtr := flag.String("umask", "", "Umask for the files written, e.g. 077, overriding self.umask from the config")
+	affinityPtr := flag.String("affinity", "", "Run on the given CPUs, e.g. 0-3, overriding self.affinity from the config")
+	benchmarkPtr := flag.Bool("benchmark", false, "Measure the frequency under load with processor boosting toggled, to show its effect")
+	probeBoostPtr := flag.Bool("probe-boost", false, "Check whether processor boosting actually happens under load, not only whether it is enabled")
+	benchmarkDurationPtr := flag.Duration("benchmark-duration", 2*time.Second, "How long each -benchmark or -probe-boost measurement keeps a CPU busy (at most 10s)")
+	strictPtr := flag.Bool("strict", false, "Abort on unknown keys or invalid values in the config, instead of warning about them")
+	waitOnlinePtr := flag.Bool("wait-online", false, "Wait until every CPU is online before doing anything, e.g. early at boot")
+	waitOnlineTimeoutPtr := flag.Duration("wait-online-timeout", 30*time.Second, "How long -wait-online waits for the CPUs to come online")
+	allHostCPUsPtr := flag.Bool("all-host-cpus", false, "Act on every online CPU, instead of only the ones allowed by our cpuset, e.g. in a container")
+	maxCPUsPtr := flag.Int("max-cpus", 0, "Limit per-core operations to the first N CPUs (0 means all)")
+	dumpMSRRangePtr := flag.String("dump-msr-range", "", "Display the MSRs in the given range, e.g. 0xC0010000-0xC0010020, for debugging")
+	dumpMSRCPUPtr := flag.Int("dump-msr-cpu", 0, "CPU whose MSRs -dump-msr-range displays")
+	dumpPMTablePtr := flag.Bool("dump-pmtable", false, "Display the SMU power management table, naming the known fields, for debugging")
+	pstatesPtr := flag.Bool("pstates", false, "Display the software P-states, i.e. their frequency and voltage")
+	perCorePtr := flag.Bool("per-core", false, "Display per-core status")
+	watchPtr := flag.Bool("watch", false, "Display status periodically, including per-core boost residency, until interrupted")
+	intervalPtr := flag.Duration("interval", 5*time.Second, "Interval between updates in -watch mode, or applies in -daemon mode")
+	applyThenWatchPtr := flag.Bool("apply-once-then-watch", false, "Apply the config, then display the status every interval, reporting drift from the config without correcting it, until interrupted")
+	daemonPtr := flag.Bool("daemon", false, "Keep applying the config every interval; SIGUSR1 applies immediately, SIGUSR2 displays the status")
+	flag.IntVar(&smu.RetryBudget, "smu-retries", smu.RetryBudget, "How many times to retry SMU commands rejected because the SMU is busy, and SMU reads failing transiently")
+	flag.BoolVar(&assumeYes, "yes", false, "Do not ask for confirmation before risky changes, e.g. disabling C6 C-state")
+	flag.IntVar(&applyRepeat, "repeat", applyRepeat, "Apply each setting this many times in a row, for firmware on which a first write may not stick")
+	flag.DurationVar(&applyRepeatDelay, "repeat-delay", applyRepeatDelay, "Pause between the attempts of -repeat")
+	noAutoModprobePtr := flag.Bool("no-auto-modprobe", false, "Do not load the msr module automatically when it is needed but not loaded")
+	flag.BoolVar(&force, "force", false, "Apply changes that may keep the machine from booting, e.g. P-states, without asking for confirmation")
+	formatTemplatePtr := flag.String("format-template", "", "Go text/template for each action line, e.g. '{{.Setting}} {{.Action}} {{.Result}}'")
+
+	flag.Parse()
+
+	// The processor family and the CPUs acted on affect every mode, including
+	// the config check, Nagios, Prometheus and drift detection below.
+	if *assumeFamilyPtr != "" {
+		family, err := strconv.ParseInt(*assumeFamilyPtr, 0, 0)
+		if err != nil || family <= 0 {
+			fmt.Printf("Error: invalid processor family %q.\n", *assumeFamilyPtr)
+			os.Exit(1)
+		}
+		cpuinfo.AssumeFamily(int(family))
+		// Assuming a family is a deliberate choice, so the detected one
+		// is only warned about.
+		skipFamilyCheck = true
 	}
 
-	fmt.Printf("Disabling processor boosting:   ")
-	err := boosting.Disable()
-	if err != nil {
-		fmt.Printf("oops: %v\n", err)
-		return
+	if *maxCPUsPtr < 0 {
+		fmt.Printf("Error: invalid -max-cpus %d.\n", *maxCPUsPtr)
+		os.Exit(1)
 	}
-	fmt.Println("SUCCESS")
-}
+	cpulist.SetMax(*maxCPUsPtr)
+	cpulist.SetAllHostCPUs(*allHostCPUsPtr)
 
-// enableBoosting enables processor boosting.
-func enableBoosting() {
-	if !boosting.Available() {
-		fmt.Println("Processor boosting unavailable - check if AMD Cool'n'Quiet enabled and cpufreq module loaded.")
-		return
+	if statusWorkers < 1 {
+		fmt.Println("Error: -parallel-status must be at least 1.")
+		os.Exit(1)
 	}
 
-	fmt.Printf("Enabling processor boosting:   ")
-	err := boosting.Enable()
-	if err != nil {
-		fmt.Printf("oops: %v\n", err)
-		return
+	// Unless given a directory of its own, the config cache follows
+	// -output-dir, as it is written too.
+	if outputDir != "" {
+		cacheDirSet := false
+		flag.Visit(func(f *flag.Flag) {
+			cacheDirSet = cacheDirSet || f.Name == "config-cache-dir"
+		})
+		if !cacheDirSet {
+			configCacheDir = filepath.Join(outputDir, "cache")
+		}
 	}
-	fmt.Println("SUCCESS")
-}
 
-// disableASLR disables address space layout randomization (ASLR).
-func disableASLR() {
-	fmt.Printf("Disabling address space layout randomization (ASLR):   ")
-	err := aslr.Disable()
+	format, err := selectOutputFormat(*formatPtr, jsonOutput, *nagiosPtr)
 	if err != nil {
-		fmt.Printf("oops: %v\n", err)
-		return
+		fmt.Printf("Error: %v.\n", err)
+		os.Exit(1)
 	}
-	fmt.Println("SUCCESS")
-}
+	outputFormat = format
+	jsonOutput = outputFormat == outputJSON
 
-// enableASLR enables address space layout randomization (ASLR).
-func enableASLR() {
-	fmt.Printf("Enabling address space layout randomization (ASLR):   ")
-	err := aslr.Enable()
-	if err != nil {
-		fmt.Printf("oops: %v\n", err)
-		return
+	if *applyThenWatchPtr && (outputFormat != outputText || *daemonPtr) {
+		fmt.Println("Error: -apply-once-then-watch cannot be combined with -json, another -format or -daemon.")
+		os.Exit(1)
 	}
-	fmt.Println("SUCCESS")
-}
 
-// showStatus displays the current status, if available, of C6 C-state,
-// processor boosting and address space layout randomization (ASLR).
-func showStatus() {
-	fmt.Println("")
-	if c6.Available() {
-		psStatus := "Power Supply Idle Control workaround is ENABLED."
-		psEnabled, err := c6.PackageEnabled()
-		if err == nil {
-			if psEnabled {
-				psStatus = "Power Supply Idle Control workaround is DISABLED."
-			}
-		} else {
-			psStatus = fmt.Sprintf("Error while obtaining status of Power Supply Idle Control workaround: %v", err)
+	if applyRepeat < 1 || applyRepeatDelay < 0 {
+		fmt.Println("Error: -repeat must be at least 1, and -repeat-delay not negative.")
+		os.Exit(1)
+	}
+
+	msr.AutoModprobe = !*noAutoModprobePtr
+	msr.LogRecovery = func(message string) {
+		// The Prometheus output must only have metrics.
+		switch {
+		case outputFormat == outputPrometheus:
+			fmt.Fprintf(os.Stderr, "Note: %s.\n", message)
+		case !jsonOutput:
+			fmt.Printf("Note: %s.\n", message)
 		}
-		fmt.Println(psStatus)
 	}
 
-	if c6.Available() {
-		c6Status := "C6 C-state is DISABLED."
-		c6Enabled, err := c6.Enabled()
-		if err == nil {
-			if c6Enabled {
-				c6Status = "C6 C-state is ENABLED."
-			}
-		} else {
-			c6Status = fmt.Sprintf("Error while obtaining status of C6 C-state: %v", err)
+	if *configFormatPtr != "" {
+		format, err := parseConfigFormat(*configFormatPtr)
+		if err != nil {
+			fmt.Printf("Error: %v.\n", err)
+			os.Exit(1)
+		}
+		configFormat = format
+	}
+
+	if *formatTemplatePtr != "" {
+		t, err := template.New("format").Parse(*formatTemplatePtr)
+		if err != nil {
+			fmt.Printf("Error: invalid format template: %v.\n", err)
+			os.Exit(1)
 		}
-		fmt.Println(c6Status)
+		formatTemplate = t
 	}
 
-	aslrStatus := "ASLR is DISABLED."
-	aslrEnabled, err := aslr.Enabled()
-	if err == nil {
-		if aslrEnabled {
-			aslrStatus = "ASLR is ENABLED."
+	if *jsonSchemaPtr {
+		if err := showConfigSchema(); err != nil {
+			fmt.Printf("Error: unable to produce JSON Schema: %v.\n", err)
+			os.Exit(1)
 		}
-	} else {
-		aslrStatus = fmt.Sprintf("Error while obtaining status of ASLR: %v", err)
+		return
+	}
+
+	// Checking the config is possible on any machine, so that it can be done
+	// before deploying it.
+	if *configCheckPtr {
+		os.Exit(configCheck(*configFilePtr, *configDirPtr, false))
+	}
+	if *configParsePtr {
+		os.Exit(configCheck(*configFilePtr, *configDirPtr, true))
 	}
-	fmt.Println(aslrStatus)
 
-	if boosting.Available() {
-		boostingEnabled, err := boosting.Enabled()
-		boostingStatus := "Processor boosting is DISABLED."
-		if err == nil {
-			if boostingEnabled {
-				boostingStatus = "Processor boosting is ENABLED."
+	// Nagios mode prints a single line and reports through the exit code, so
+	// it must not print the banner.
+	switch outputFormat {
+	case outputNagios:
+		os.Exit(nagiosCheck(*configFilePtr, *configDirPtr))
+	case outputPrometheus:
+		// Only the status is reported, so changes would be silently
+		// ignored.
+		for _, f := range []bool{*enableC6Ptr, *disableC6Ptr, *enablePSICWorkaroundPtr, *disablePSICWorkaroundPtr, *enableBoostingPtr, *disableBoostingPtr, *enableASLRPtr, *disableASLRPtr, *benchmarkPtr, *probeBoostPtr} {
+			if f {
+				fmt.Fprintln(os.Stderr, "Error: -format=prometheus only reports the status, and cannot be combined with flags changing settings, -benchmark or -probe-boost.")
+				os.Exit(1)
 			}
-		} else {
-			boostingStatus = fmt.Sprintf("Error while obtaining status of processor boosting: %v", err)
 		}
-		fmt.Println(boostingStatus)
+		os.Exit(prometheusStatus(*configFilePtr, *configDirPtr))
+	}
+
+	// Like Nagios mode, drift detection reports through the exit code and
+	// output meant for tools.
+	if *diffExitCodePtr {
+		if *configFilePtr == "" && *configDirPtr == "" {
+			fmt.Println("Error: -diff-exit-code requires a config file.")
+			os.Exit(driftError)
+		}
+		filter, err := newSettingFilter(*onlyPtr, *skipPtr)
+		if err != nil {
+			fmt.Printf("Error: %v.\n", err)
+			os.Exit(driftError)
+		}
+		os.Exit(checkDrift(*configFilePtr, *configDirPtr, filter))
+	}
+
+	// Listing the settings needs neither the banner nor privileges, and is
+	// useful for checking support on any machine.
+	if *listSettingsPtr {
+		listSettings()
+		return
 	}
-}
 
-func handleConfigurationFile(configFile string) {
-	settings := rsSettings{}
+	// Like the settings list, the processor information is meant to be
+	// obtainable on any machine, so it is not subject to the sanity check.
+	if *cpuInfoPtr {
+		showProcessorInfo()
+		return
+	}
 
-	// Reading and parsing the configuration file provided.
-	buf, err := ioutil.ReadFile(configFile)
+	if !jsonOutput && !quietSuccess {
+		printBanner()
+	}
+
+	// Only applying settings requires root.
+	applying := *configFilePtr != "" || *configDirPtr != "" || *benchmarkPtr
+	for _, f := range []bool{*enableC6Ptr, *disableC6Ptr, *enablePSICWorkaroundPtr, *disablePSICWorkaroundPtr, *enableBoostingPtr, *disableBoostingPtr, *enableASLRPtr, *disableASLRPtr} {
+		applying = applying || f
+	}
+
+	if *assumeFamilyPtr != "" && !jsonOutput && !quietSuccess {
+		fmt.Printf("Assuming processor family 0x%X (detected 0x%X).\n\n", cpuinfo.Family(), cpuinfo.DetectedFamily())
+	}
+
+	if cpuinfo.Simulated && !jsonOutput && !quietSuccess {
+		fmt.Printf("Simulating %s; MSRs are not written to the hardware.\n\n", cpuid.CPU.BrandName)
+	}
+
+	err = sanityCheck(applying)
 	if err != nil {
-		fmt.Printf("Error: unable to read contents of config file %q: %v.\n", configFile, err)
+		fmt.Printf("Error: %v.\n", err)
 		return
 	}
 
-	if _, err = toml.Decode(string(buf), &settings); err != nil {
-		fmt.Printf("Error: problem parsing config file %q: %v.\n\n", configFile, err)
+	// Otherwise, per-core operations could act on only some of the CPUs.
+	if *waitOnlinePtr {
+		if err := cpulist.WaitOnline(*waitOnlineTimeoutPtr); err != nil {
+			fmt.Printf("Error: %v.\n", err)
+			os.Exit(1)
+		}
+	}
+
+	self := &selfSettings{nice: *nicePtr, affinity: *affinityPtr, umask: *umaskPtr}
+	usingConfig := *configFilePtr != "" || *configDirPtr != ""
+	// With a config file, we can only adjust ourselves once it is loaded.
+	if !usingConfig {
+		if err := self.apply(); err != nil {
+			fmt.Printf("Error: %v.\n", err)
+			return
+		}
+	}
+
+	if *probeBoostPtr {
+		if err := probeBoost(*benchmarkDurationPtr); err != nil {
+			fmt.Printf("Error: %v.\n", err)
+			os.Exit(1)
+		}
 		return
 	}
 
-	// Now we perform the actions indicated by the config file.
-	fmt.Printf("Config file: %q\n", configFile)
-	switch strings.ToLower(settings.C6) {
-	case "enable":
-		enableC6()
-	case "disable":
-		disableC6()
+	if *benchmarkPtr {
+		if err := benchmark(*benchmarkDurationPtr); err != nil {
+			fmt.Printf("Error: %v.\n", err)
+			os.Exit(1)
+		}
+		return
 	}
-	switch strings.ToLower(settings.PSICWorkaround) {
-	case "enable":
-		enablePSICWorkaround()
-	case "disable":
-		disablePSICWorkaround()
+
+	if *watchPtr {
+		watch(*intervalPtr)
+		return
 	}
-	switch strings.ToLower(settings.Boosting) {
-	case "enable":
-		enableBoosting()
-	case "disable":
-		disableBoosting()
+
+	if *dumpMSRRangePtr != "" {
+		if err := dumpMSRRange(*dumpMSRRangePtr, *dumpMSRCPUPtr); err != nil {
+			fmt.Printf("Error: %v.\n", err)
+			os.Exit(1)
+		}
+		return
 	}
-	switch strings.ToLower(settings.ASLR) {
-	case "enable":
-		enableASLR()
-	case "disable":
-		disableASLR()
+
+	if *dumpPMTablePtr {
+		if err := dumpPMTable(); err != nil {
+			fmt.Printf("Error: %v.\n", err)
+			os.Exit(1)
+		}
+		return
 	}
 
-	// Current status of both C6 C-state and processor boosting.
-	showStatus()
-}
+	if *pstatesPtr {
+		if err := showPStates(); err != nil {
+			fmt.Printf("Error: %v.\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 
-func main() {
-	fmt.Printf("%s %s\n%s\n\n", program, version, copyright)
+	if *perCorePtr {
+		showPerCoreStatus()
+		return
+	}
 
-	err := sanityCheck()
-	if err != nil {
-		fmt.Printf("Error: %v.\n", err)
+	if *exportConfigPtr != "" {
+		path := outputPath(*exportConfigPtr)
+		if err := exportConfiguration(path, false); err != nil {
+			fmt.Printf("Error: unable to export config to %q: %v.\n", path, err)
+			return
+		}
+		fmt.Printf("Current state exported to %q.\n", path)
 		return
 	}
 
-	configFilePtr := flag.String("config", "", "ryzen-stabilizator config file")
-	enablePSICWorkaroundPtr := flag.Bool("enable-psicworkaround", false, "Enable Power Supply Idle Control Workaround")
-	disablePSICWorkaroundPtr := flag.Bool("disable-psicworkaround", false, "Disable Power Supply Idle Control Workaround")
-	enableC6Ptr := flag.Bool("enable-c6", false, "Enable C6 C-state")
-	disableC6Ptr := flag.Bool("disable-c6", false, "Disable C6 C-state")
-	enableBoostingPtr := flag.Bool("enable-boosting", false, "Enable processor boosting")
-	disableBoostingPtr := flag.Bool("disable-boosting", false, "Disable processor boosting")
-	enableASLRPtr := flag.Bool("enable-aslr", false, "Enable address space layout randomization (ASLR)")
-	disableASLRPtr := flag.Bool("disable-aslr", false, "Disable address space layout randomization (ASLR)")
+	if *dumpConfigDefaultsPtr != "" {
+		path := outputPath(*dumpConfigDefaultsPtr)
+		if err := exportConfiguration(path, true); err != nil {
+			fmt.Printf("Error: unable to write config template to %q: %v.\n", path, err)
+			return
+		}
+		fmt.Printf("Config template written to %q.\n", path)
+		return
+	}
 
-	flag.Parse()
+	report := &applyReport{}
 
 	// Handle config file with associated profile.
-	if *configFilePtr != "" {
-		handleConfigurationFile(*configFilePtr)
+	if usingConfig {
+		filter, err := newSettingFilter(*onlyPtr, *skipPtr)
+		if err != nil {
+			fmt.Printf("Error: %v.\n\n", err)
+			return
+		}
+		cfg, err := loadConfiguration(*configFilePtr, *configDirPtr)
+		if err != nil {
+			fmt.Printf("Error: %v.\n\n", err)
+			return
+		}
+		if !jsonOutput && !quietSuccess {
+			for _, f := range cfg.files {
+				fmt.Printf("Config file: %q\n", f)
+			}
+		}
+		if !jsonOutput {
+			for _, w := range cfg.warnings {
+				fmt.Printf("Warning: %s.\n", w)
+			}
+		}
+		strict := *strictPtr || cfg.strict()
+		if tooOld := cfg.kernelTooOld(); tooOld != "" {
+			if strict {
+				fmt.Printf("Error: %s.\n", tooOld)
+				os.Exit(1)
+			}
+			if !jsonOutput {
+				fmt.Printf("Warning: %s; skipping the settings.\n", tooOld)
+			}
+		}
+		if problems := cfg.problems(); len(problems) > 0 {
+			for _, p := range problems {
+				if strict {
+					fmt.Printf("Error: %s.\n", p)
+				} else if !jsonOutput {
+					fmt.Printf("Warning: %s.\n", p)
+				}
+			}
+			if strict {
+				os.Exit(1)
+			}
+		}
+		self.fromConfiguration(cfg)
+		if err := self.apply(); err != nil {
+			fmt.Printf("Error: %v.\n\n", err)
+			return
+		}
+		if *daemonPtr {
+			if *configFilePtr == stdinConfig {
+				fmt.Println("Error: -daemon cannot reload the config from the standard input.")
+				os.Exit(1)
+			}
+			daemon(*configFilePtr, *configDirPtr, filter, *intervalPtr)
+			return
+		}
+		handleConfiguration(cfg, filter, report)
+		runPostApplyHook(cfg, report)
+		finish(report, cfg)
+		if *applyThenWatchPtr {
+			watchDrift(cfg, filter, *intervalPtr)
+		}
 		return
 	}
 
-	// Regular handling of command-line arguments, if we are not using config
-	// file with predefined profiles.
-	// C6.
-	switch {
-	case *disableC6Ptr:
-		disableC6()
-	case *enableC6Ptr:
-		enableC6()
+	if *daemonPtr {
+		fmt.Println("Error: -daemon requires a config file.")
+		os.Exit(1)
 	}
 
-	// Power Supply Idle Control Workaround.
-	switch {
-	case *disablePSICWorkaroundPtr:
-		disablePSICWorkaround()
-	case *enablePSICWorkaroundPtr:
-		enablePSICWorkaround()
+	if *applyThenWatchPtr {
+		fmt.Println("Error: -apply-once-then-watch requires a config file.")
+		os.Exit(1)
 	}
 
-	// Boosting.
-	switch {
-	case *disableBoostingPtr:
-		disableBoosting()
-	case *enableBoostingPtr:
-		enableBoosting()
+	// Regular handling of command-line arguments, if we are not using config
+	// file with predefined profiles.
+	flagSettings := []struct {
+		name    string
+		disable bool
+		enable  bool
+	}{
+		{"c6", *disableC6Ptr, *enableC6Ptr},
+		{"psicworkaround", *disablePSICWorkaroundPtr, *enablePSICWorkaroundPtr},
+		{"boosting", *disableBoostingPtr, *enableBoostingPtr},
+		{"aslr", *disableASLRPtr, *enableASLRPtr},
 	}
-
-	// ASLR.
-	switch {
-	case *disableASLRPtr:
-		disableASLR()
-	case *enableASLRPtr:
-		enableASLR()
+	for _, f := range flagSettings {
+		switch {
+		case f.disable:
+			logProvenance(f.name, "disable", "flag:-disable-"+f.name)
+			report.apply(setting.Lookup(f.name), "disable")
+		case f.enable:
+			logProvenance(f.name, "enable", "flag:-enable-"+f.name)
+			report.apply(setting.Lookup(f.name), "enable")
+		}
 	}
 
-	// Current status of both C6 C-state and processor boosting.
-	showStatus()
+	finish(report, nil)
 }
@@ -0,0 +1,112 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mce
+
+import (
+	"strings"
+	"syscall"
+)
+
+const (
+	kmsgFile = "/dev/kmsg"
+)
+
+// Signature is a kind of kernel log message often seen on unstable Zen
+// systems, e.g. with C6 C-state issues, told by substrings of the message.
+type Signature struct {
+	Name    string
+	Matches []string
+}
+
+// Signatures are the kernel log messages LogSignatures counts. Matching them
+// is a heuristic: they may as well be caused by faulty software or other
+// hardware.
+var Signatures = []Signature{
+	// Not every "mce: " message is an error, e.g. "mce: CPU0: Thermal
+	// monitoring enabled" at boot, so only the ones reporting one match.
+	{"machine check", []string{"Machine check events logged", "Machine Check Exception", "Fatal machine check"}},
+	{"hardware error", []string{"[Hardware Error]"}},
+	{"segfault", []string{" segfault at "}},
+	{"general protection fault", []string{"general protection fault", "general protection ip:"}},
+	{"soft or hard lockup", []string{"soft lockup", "hard LOCKUP"}},
+	{"RCU stall", []string{"rcu_sched self-detected stall", "rcu_preempt self-detected stall", "detected stalls on CPUs"}},
+}
+
+// SignatureCount is how many of the messages in the kernel log match a
+// signature.
+type SignatureCount struct {
+	Signature string
+	Count     int
+}
+
+// readKernelLog returns the messages in the kernel ring buffer, reading them
+// from /dev/kmsg, which is readable by root only on most systems. Each read
+// returns a single record, `<priority>,<sequence>,<timestamp>,<flags>;message',
+// and fails with EAGAIN once every record was read.
+func readKernelLog() ([]string, error) {
+	fd, err := syscall.Open(kmsgFile, syscall.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer syscall.Close(fd)
+
+	var messages []string
+	buf := make([]byte, 8192)
+	for {
+		n, err := syscall.Read(fd, buf)
+		switch {
+		case err == syscall.EAGAIN:
+			return messages, nil
+		case err == syscall.EPIPE:
+			// Records were overwritten while we read them; go on
+			// from the oldest one still there.
+			continue
+		case err != nil:
+			return nil, err
+		case n == 0:
+			return messages, nil
+		}
+		record := string(buf[:n])
+		if i := strings.IndexByte(record, ';'); i >= 0 {
+			record = record[i+1:]
+		}
+		// Continuation lines, with key=value pairs, follow the message.
+		if i := strings.IndexByte(record, '\n'); i >= 0 {
+			record = record[:i]
+		}
+		messages = append(messages, record)
+	}
+}
+
+// LogSignatures returns, for each of the Signatures, how many messages in the
+// kernel ring buffer match it.
+func LogSignatures() ([]SignatureCount, error) {
+	messages, err := readKernelLog()
+	if err != nil {
+		return nil, err
+	}
+	counts := make([]SignatureCount, len(Signatures))
+	for i, s := range Signatures {
+		counts[i].Signature = s.Name
+		for _, m := range messages {
+			for _, match := range s.Matches {
+				if strings.Contains(m, match) {
+					counts[i].Count++
+					break
+				}
+			}
+		}
+	}
+	return counts, nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mce
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	machinecheckDir = "/sys/devices/system/machinecheck"
+	interruptsFile  = "/proc/interrupts"
+)
+
+// Available returns a boolean indicating whether the kernel has machine check
+// support enabled.
+func Available() bool {
+	if _, err := os.Stat(machinecheckDir); err == nil {
+		return true
+	}
+	return false
+}
+
+// Count returns the number of machine check exceptions (MCEs) since boot,
+// summed across every CPU. The kernel accounts for them in the `MCE' line of
+// /proc/interrupts, which has one column per CPU followed by a description:
+//
+//	MCE:          0          0   Machine check exceptions
+func Count() (uint64, error) {
+	f, err := os.Open(interruptsFile)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || fields[0] != "MCE:" {
+			continue
+		}
+		var total uint64
+		for _, field := range fields[1:] {
+			n, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				// We reached the description.
+				break
+			}
+			total += n
+		}
+		return total, nil
+	}
+	if err = scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("no MCE accounting found in %s", interruptsFile)
+}
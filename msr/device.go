@@ -0,0 +1,34 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !simulate
+// +build !simulate
+
+package msr
+
+import (
+	"fmt"
+	"os"
+)
+
+// open opens the MSR device of a given CPU, either for reading or writing, as
+// given by flag.
+func open(cpu, flag int) (device, error) {
+	return os.OpenFile(fmt.Sprintf("/dev/cpu/%d/msr", cpu), flag, 0666)
+}
+
+// deviceExists returns true if the MSR device of a given CPU exists.
+func deviceExists(cpu int) bool {
+	_, err := os.Stat(fmt.Sprintf("/dev/cpu/%d/msr", cpu))
+	return err == nil
+}
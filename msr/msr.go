@@ -0,0 +1,217 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package msr
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/kernel"
+)
+
+var (
+	// ErrNotLoaded indicates the msr module is not loaded.
+	ErrNotLoaded = errors.New("check if msr module loaded")
+	// ErrNoDeviceNodes indicates msr support is present in the kernel, e.g.
+	// built-in, but the device nodes were not created, so loading the module
+	// will not help.
+	ErrNoDeviceNodes = errors.New("msr support is in the kernel, but /dev/cpu/*/msr is missing; check your udev rules, or create the device nodes with mknod (character device, major 202)")
+)
+
+var (
+	// AutoModprobe indicates that, if the MSR devices are missing because
+	// the msr module is not loaded, it should be loaded with modprobe, once,
+	// and the access retried.
+	AutoModprobe = true
+	// LogRecovery, if set, is called with a message when the msr module was
+	// loaded automatically.
+	LogRecovery func(message string)
+
+	modprobeOnce sync.Once
+	modprobed    bool
+)
+
+// modprobe loads the msr module, if AutoModprobe is set and the module is
+// neither loaded nor built in. It is only attempted once, and returns whether
+// the module was loaded.
+func modprobe() bool {
+	modprobeOnce.Do(func() {
+		if !AutoModprobe || !kernel.HasCapabilities(kernel.CapSysModule) {
+			return
+		}
+		if _, err := os.Stat("/sys/module/msr"); err == nil || builtIn() {
+			return
+		}
+		if err := exec.Command("modprobe", "msr").Run(); err != nil {
+			return
+		}
+		modprobed = true
+		if LogRecovery != nil {
+			LogRecovery("the msr module was not loaded, so it was loaded with modprobe")
+		}
+	})
+	return modprobed
+}
+
+// openRecovering opens the MSR device of a given CPU, like open, but if it
+// does not exist, it tries loading the msr module first and opens it again.
+func openRecovering(cpu, flag int) (device, error) {
+	f, err := open(cpu, flag)
+	if os.IsNotExist(err) && modprobe() {
+		return open(cpu, flag)
+	}
+	return f, err
+}
+
+// device is the MSR device of a CPU, where the offset of each read or write
+// selects the register.
+type device interface {
+	io.ReaderAt
+	io.WriterAt
+	io.Closer
+}
+
+// Read reads the MSR of a given CPU at a given offset. MSR stands for
+// model-specific register.
+func Read(offset int64, cpu int) (uint64, error) {
+	f, err := openRecovering(cpu, os.O_RDONLY)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	data := make([]byte, 8)
+	if _, err = f.ReadAt(data, offset); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(data), nil
+}
+
+// Write writes a value to a specific CPU MSR at a given offset.
+func Write(offset int64, cpu int, value uint64) error {
+	f, err := openRecovering(cpu, os.O_WRONLY)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	data := make([]byte, 8)
+	binary.LittleEndian.PutUint64(data, value)
+	_, err = f.WriteAt(data, offset)
+	return err
+}
+
+// Value is a value to be written to the MSR at a given offset.
+type Value struct {
+	Offset int64
+	Value  uint64
+}
+
+// WriteAll writes the given values to the MSRs of a specific CPU, opening its
+// MSR device only once. It stops at the first failure.
+func WriteAll(cpu int, values []Value) error {
+	f, err := openRecovering(cpu, os.O_WRONLY)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	data := make([]byte, 8)
+	for _, v := range values {
+		binary.LittleEndian.PutUint64(data, v.Value)
+		if _, err = f.WriteAt(data, v.Offset); err != nil {
+			return fmt.Errorf("unable to write MSR 0x%X: %v", v.Offset, err)
+		}
+	}
+	return nil
+}
+
+// Available returns a boolean indicating whether we have MSR access available
+// or not. We require the `msr' module for it to be available.
+func Available() bool {
+	if deviceExists(0) {
+		return true
+	}
+	return modprobe() && deviceExists(0)
+}
+
+// Check returns nil if we have MSR access available. Otherwise, it tells
+// apart the msr module not being loaded (ErrNotLoaded) from msr support being
+// in the kernel without the device nodes (ErrNoDeviceNodes), as their remedies
+// differ.
+func Check() error {
+	if Available() {
+		return nil
+	}
+	if _, err := os.Stat("/sys/module/msr"); err == nil || builtIn() {
+		return ErrNoDeviceNodes
+	}
+	return ErrNotLoaded
+}
+
+// builtIn returns true if msr support is built into the running kernel,
+// according to either the list of built-in modules or the kernel config.
+func builtIn() bool {
+	release, err := ioutil.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		return false
+	}
+	kernel := strings.TrimSpace(string(release))
+
+	if f, err := os.Open("/lib/modules/" + kernel + "/modules.builtin"); err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if strings.HasSuffix(scanner.Text(), "/msr.ko") {
+				return true
+			}
+		}
+	}
+
+	if f, err := os.Open("/proc/config.gz"); err == nil {
+		defer f.Close()
+		if r, err := gzip.NewReader(f); err == nil && configBuiltIn(r) {
+			return true
+		}
+	}
+
+	if f, err := os.Open("/boot/config-" + kernel); err == nil {
+		defer f.Close()
+		if configBuiltIn(f) {
+			return true
+		}
+	}
+	return false
+}
+
+// configBuiltIn returns true if the given kernel config has msr support built
+// in.
+func configBuiltIn(r io.Reader) bool {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if scanner.Text() == "CONFIG_X86_MSR=y" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build simulate
+// +build simulate
+
+package msr
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/cpulist"
+)
+
+// The MSRs are simulated in memory, so that development does not need Ryzen
+// hardware. The registers start out zeroed, except for the following ones,
+// which match the defaults of a Zen 2 processor.
+var (
+	simulatedDefaults = map[int64]uint64{
+		// C6 package enabled.
+		0xC0010292: 1 << 32,
+		// C6 core enabled.
+		0xC0010296: (1 << 22) | (1 << 14) | (1 << 6),
+		// P-states P0, P1 and P2: 3600 MHz at 1.1 V, 2800 MHz at 0.95 V and
+		// 2200 MHz at 0.875 V.
+		0xC0010064: 0x8000000000120890,
+		0xC0010065: 0x8000000000180870,
+		0xC0010066: 0x80000000001B0858,
+	}
+
+	simulatedMu   sync.Mutex
+	simulatedMSRs = map[int]map[int64]uint64{}
+)
+
+// simulatedDevice is the simulated MSR device of a CPU.
+type simulatedDevice struct {
+	cpu int
+}
+
+// open opens the simulated MSR device of a given CPU. The flag is ignored.
+func open(cpu, flag int) (device, error) {
+	if !deviceExists(cpu) {
+		return nil, fmt.Errorf("no simulated MSR device for CPU %d", cpu)
+	}
+	return simulatedDevice{cpu}, nil
+}
+
+// deviceExists returns true if a given CPU is simulated.
+func deviceExists(cpu int) bool {
+	return cpulist.Includes(cpu)
+}
+
+// registers returns the simulated MSRs of the CPU. simulatedMu must be held.
+func (d simulatedDevice) registers() map[int64]uint64 {
+	regs, ok := simulatedMSRs[d.cpu]
+	if !ok {
+		regs = map[int64]uint64{}
+		for offset, value := range simulatedDefaults {
+			regs[offset] = value
+		}
+		simulatedMSRs[d.cpu] = regs
+	}
+	return regs
+}
+
+// ReadAt reads the simulated MSR at offset, which must be 8 bytes long.
+func (d simulatedDevice) ReadAt(p []byte, offset int64) (int, error) {
+	if len(p) != 8 {
+		return 0, io.ErrShortBuffer
+	}
+	simulatedMu.Lock()
+	defer simulatedMu.Unlock()
+	binary.LittleEndian.PutUint64(p, d.registers()[offset])
+	return len(p), nil
+}
+
+// WriteAt writes the simulated MSR at offset, which must be 8 bytes long.
+func (d simulatedDevice) WriteAt(p []byte, offset int64) (int, error) {
+	if len(p) != 8 {
+		return 0, io.ErrShortWrite
+	}
+	simulatedMu.Lock()
+	defer simulatedMu.Unlock()
+	d.registers()[offset] = binary.LittleEndian.Uint64(p)
+	return len(p), nil
+}
+
+// Close does nothing, as there is nothing to release.
+func (d simulatedDevice) Close() error {
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Exit codes understood by Nagios/Icinga for plugin results.
+const (
+	nagiosOK       = 0
+	nagiosWarning  = 1
+	nagiosCritical = 2
+)
+
+// nagiosCheck compares the current state to the one described by the config
+// file and prints a one-line summary in the format expected from a Nagios
+// plugin. It returns the exit code to be used: OK if every setting matches,
+// WARN if some setting could not be checked, and CRIT if any of them differs.
+func nagiosCheck(configFile, configDir string) int {
+	if configFile == "" && configDir == "" {
+		fmt.Println("WARN: no config file provided to compare against")
+		return nagiosWarning
+	}
+	if err := sanityCheck(false); err != nil {
+		fmt.Printf("WARN: %v\n", err)
+		return nagiosWarning
+	}
+	cfg, err := loadConfiguration(configFile, configDir)
+	if err != nil {
+		fmt.Printf("WARN: %v\n", err)
+		return nagiosWarning
+	}
+
+	var critical, warning, ok []string
+	for _, c := range compareSettings(cfg) {
+		name := c.setting.Name()
+		switch {
+		case !c.configured:
+			// Not managed by the config file.
+		case c.unavailable != nil:
+			warning = append(warning, fmt.Sprintf("%s unavailable", name))
+		case c.err != nil:
+			warning = append(warning, fmt.Sprintf("%s unreadable: %v", name, c.err))
+		case c.mismatch():
+			critical = append(critical, fmt.Sprintf("%s expected %s got %s", name, displayValue(c.setting, c.desired), displayValue(c.setting, c.current)))
+		default:
+			ok = append(ok, fmt.Sprintf("%s %s", name, displayValue(c.setting, c.current)))
+		}
+	}
+
+	switch {
+	case len(critical) > 0:
+		fmt.Printf("CRIT: %s\n", strings.Join(append(critical, warning...), ", "))
+		return nagiosCritical
+	case len(warning) > 0:
+		fmt.Printf("WARN: %s\n", strings.Join(warning, ", "))
+		return nagiosWarning
+	}
+	fmt.Printf("OK: %s\n", strings.Join(ok, ", "))
+	return nagiosOK
+}
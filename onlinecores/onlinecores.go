@@ -0,0 +1,120 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package onlinecores
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/cpulist"
+)
+
+const (
+	cpuDir = "/sys/devices/system/cpu"
+)
+
+var (
+	// ErrBootCPU is returned when asked to offline cpu0, which the kernel
+	// uses for housekeeping and usually refuses to offline anyway.
+	ErrBootCPU = errors.New("cpu0 cannot be offlined")
+)
+
+// Available returns a boolean indicating whether CPU hotplug is available,
+// i.e. whether any CPU can be brought offline and online.
+func Available() bool {
+	present, err := cpulist.Present()
+	if err != nil {
+		return false
+	}
+	for _, c := range present {
+		if _, err := os.Stat(fmt.Sprintf("%s/cpu%d/online", cpuDir, c)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// setOnline brings the given CPU online or offline, depending on whether the
+// provided parameter is true or false, respectively.
+func setOnline(core int, online bool) error {
+	value := []byte("0")
+	if online {
+		value = []byte("1")
+	}
+	return ioutil.WriteFile(fmt.Sprintf("%s/cpu%d/online", cpuDir, core), value, 0644)
+}
+
+// Offline brings the given CPU offline. It refuses to offline cpu0.
+func Offline(core int) error {
+	if core == 0 {
+		return ErrBootCPU
+	}
+	return setOnline(core, false)
+}
+
+// Online brings the given CPU online.
+func Online(core int) error {
+	return setOnline(core, true)
+}
+
+// OnlineCores returns the CPUs currently online.
+func OnlineCores() ([]int, error) {
+	return cpulist.Online()
+}
+
+// KeepOnline brings online the given CPUs and offline every other CPU
+// present. It refuses to do so if cpu0 is not among the CPUs to keep online.
+func KeepOnline(cores []int) error {
+	keep := map[int]bool{}
+	for _, c := range cores {
+		keep[c] = true
+	}
+	if !keep[0] {
+		return ErrBootCPU
+	}
+
+	present, err := cpulist.Present()
+	if err != nil {
+		return err
+	}
+	for _, c := range cores {
+		found := false
+		for _, p := range present {
+			found = found || p == c
+		}
+		if !found {
+			return fmt.Errorf("cpu%d is not present", c)
+		}
+	}
+
+	// Bring CPUs online first, so that we never end up with fewer CPUs
+	// online than requested in the meantime.
+	for _, c := range present {
+		if keep[c] && c != 0 {
+			if err := Online(c); err != nil {
+				return fmt.Errorf("unable to bring cpu%d online: %v", c, err)
+			}
+		}
+	}
+	for _, c := range present {
+		if !keep[c] {
+			if err := Offline(c); err != nil {
+				return fmt.Errorf("unable to bring cpu%d offline: %v", c, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package onlinecores
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/cpulist"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/setting"
+)
+
+func init() {
+	setting.Register(&coresSetting{})
+}
+
+// coresSetting is the setting with the CPUs to keep online. Its value is a
+// list of CPUs, e.g. "0-7", in the format used by the kernel.
+type coresSetting struct{}
+
+// Name returns the key of the setting in the config file.
+func (c *coresSetting) Name() string {
+	return "onlinecores"
+}
+
+// Description returns the human-readable name of the setting.
+func (c *coresSetting) Description() string {
+	return "set of online CPU cores"
+}
+
+// Values returns a description of the values accepted in the config file, as
+// they cannot be listed.
+func (c *coresSetting) Values() []string {
+	return []string{"list of CPUs, e.g. 0-7"}
+}
+
+// Available reports whether CPU hotplug is available.
+func (c *coresSetting) Available() error {
+	if !Available() {
+		return errors.New("check if the kernel supports CPU hotplug")
+	}
+	return nil
+}
+
+// parse parses and sorts a list of CPUs.
+func parse(value string) ([]int, error) {
+	cores, err := cpulist.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	sort.Ints(cores)
+	return cores, nil
+}
+
+// Validate checks value is a list of CPUs including cpu0.
+func (c *coresSetting) Validate(value string) error {
+	cores, err := parse(value)
+	if err != nil {
+		return err
+	}
+	if cores[0] != 0 {
+		return ErrBootCPU
+	}
+	return nil
+}
+
+// Normalize returns the list of CPUs in value in the format returned by
+// Status, so that they can be compared.
+func (c *coresSetting) Normalize(value string) string {
+	cores, err := parse(value)
+	if err != nil {
+		return value
+	}
+	return cpulist.Format(cores)
+}
+
+// Apply keeps online the CPUs listed in value, bringing the others offline.
+func (c *coresSetting) Apply(value string) error {
+	cores, err := parse(value)
+	if err != nil {
+		return err
+	}
+	return KeepOnline(cores)
+}
+
+// Status returns the list of CPUs currently online.
+func (c *coresSetting) Status() (string, error) {
+	cores, err := OnlineCores()
+	if err != nil {
+		return "", err
+	}
+	return cpulist.Format(cores), nil
+}
+
+// Default returns every CPU present, as they are all online at boot.
+func (c *coresSetting) Default() string {
+	present, err := cpulist.Present()
+	if err != nil {
+		return ""
+	}
+	return cpulist.Format(present)
+}
+
+// Mechanism describes the sysfs files used to bring CPUs online and offline.
+func (c *coresSetting) Mechanism() string {
+	return cpuDir + "/cpu*/online"
+}
+
+// KernelParameters returns the kernel command line parameters limiting which
+// CPUs can be brought online.
+func (c *coresSetting) KernelParameters() []string {
+	return []string{"nosmt", "maxcpus", "nr_cpus"}
+}
+
+// NeedsConfirmation returns true, as bringing CPUs offline is disruptive.
+func (c *coresSetting) NeedsConfirmation(value string) bool {
+	return true
+}
+
+// NeedsForce returns whether value brings any CPU offline, which then
+// requires an explicit confirmation: without a terminal, e.g. when run by
+// systemd at boot, CPUs are only brought offline with -force.
+func (c *coresSetting) NeedsForce(value string) bool {
+	cores, err := parse(value)
+	if err != nil {
+		return true
+	}
+	present, err := cpulist.Present()
+	if err != nil {
+		return true
+	}
+	online := map[int]bool{}
+	for _, cpu := range cores {
+		online[cpu] = true
+	}
+	for _, cpu := range present {
+		if !online[cpu] {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyOrder returns OrderOnlineCores, so that the CPUs are brought online or
+// offline before the settings changed on every CPU are applied.
+func (c *coresSetting) ApplyOrder() int {
+	return setting.OrderOnlineCores
+}
@@ -0,0 +1,67 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Output formats selectable with -format.
+const (
+	outputText       = "text"
+	outputJSON       = "json"
+	outputNagios     = "nagios"
+	outputPrometheus = "prometheus"
+)
+
+// outputFormat selects how the outcome is rendered; -json and -nagios are
+// shorthands for the matching formats.
+var outputFormat = outputText
+
+// outputFormats are the formats accepted by -format.
+var outputFormats = []string{outputText, outputJSON, outputNagios, outputPrometheus}
+
+// selectOutputFormat returns the output format for the -format flag, format,
+// which may be empty, and for the shorthand flags -json and -nagios. Asking
+// for two different formats is an error.
+func selectOutputFormat(format string, json, nagios bool) (string, error) {
+	var selected []string
+	if format != "" {
+		format = strings.ToLower(format)
+		found := false
+		for _, f := range outputFormats {
+			found = found || f == format
+		}
+		if !found {
+			return "", fmt.Errorf("invalid output format %q; expected one of %s", format, strings.Join(outputFormats, ", "))
+		}
+		selected = append(selected, format)
+	}
+	if json {
+		selected = append(selected, outputJSON)
+	}
+	if nagios {
+		selected = append(selected, outputNagios)
+	}
+	if len(selected) == 0 {
+		return outputText, nil
+	}
+	sort.Strings(selected)
+	if selected[0] != selected[len(selected)-1] {
+		return "", fmt.Errorf("conflicting output formats %s and %s; use a single -format", selected[0], selected[len(selected)-1])
+	}
+	return selected[0], nil
+}
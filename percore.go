@@ -0,0 +1,141 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/cppc"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/cpulist"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/pmtable"
+)
+
+// intsToString formats a list of CPUs as a comma-separated string.
+func intsToString(values []int) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = fmt.Sprint(v)
+	}
+	return strings.Join(s, ", ")
+}
+
+// preferredCoresStatus reports the preferred cores, as ranked by CPPC.
+func preferredCoresStatus() []string {
+	if !cppc.Available() {
+		return nil
+	}
+	preferred, err := cppc.PreferredCores()
+	if err != nil {
+		return []string{fmt.Sprintf("Error while obtaining preferred cores: %v", err)}
+	}
+	return []string{fmt.Sprintf("Preferred cores (highest CPPC performance): %s.", intsToString(preferred))}
+}
+
+// showPerCoreStatus displays a table with information about each CPU.
+func showPerCoreStatus() {
+	fmt.Println("")
+	preferred := map[int]bool{}
+	if cppc.Available() {
+		cores, err := cppc.PreferredCores()
+		if err != nil {
+			fmt.Printf("Error while obtaining preferred cores: %v\n", err)
+		}
+		for _, c := range cores {
+			preferred[c] = true
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CPU\tCPPC HIGHEST\tGUARANTEED\tNOMINAL\tLOWEST NONLINEAR\tLOWEST\tSMT SIBLINGS\tLABEL")
+	cpus := cpulist.CPUs()
+	// Physical cores are told apart by their list of SMT siblings.
+	cores := map[string]bool{}
+	for _, c := range cpus {
+		// Without CPPC, or on kernels not exposing some of its limits,
+		// they are reported as unavailable.
+		perf := make([]string, len(cppc.Limits))
+		for i, limit := range cppc.Limits {
+			perf[i] = "unavailable"
+			if p, err := cppc.Perf(c, limit); err == nil {
+				perf[i] = fmt.Sprint(p)
+			}
+		}
+		siblings := "unavailable"
+		if s, err := cpulist.ThreadSiblings(c); err == nil {
+			siblings = cpulist.Format(s)
+			cores[siblings] = true
+		}
+		label := ""
+		if preferred[c] {
+			label = "preferred"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", c, strings.Join(perf, "\t"), siblings, label)
+	}
+	w.Flush()
+
+	if len(cores) > 0 {
+		fmt.Printf("\n%d logical CPUs on %d physical cores; disabling SMT would leave one CPU per core.\n", len(cpus), len(cores))
+	}
+
+	showCoreTelemetry()
+}
+
+// showCoreTelemetry displays a table with the telemetry of each physical core
+// from the PM table, if its per-core layout is known, e.g. to see how far a
+// curve optimizer offset can go: an effective frequency well below the
+// frequency of a busy core means its clock is stretched.
+func showCoreTelemetry() {
+	t, err := pmtable.Read()
+	if err != nil {
+		// The PM table needs the ryzen_smu module, which is optional.
+		return
+	}
+	if t.Cores() == 0 {
+		fmt.Printf("\nPer-core telemetry unavailable: the per-core layout of PM table version 0x%06X is not known.\n", t.Version)
+		return
+	}
+
+	fmt.Println("")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CORE\tFREQUENCY\tEFFECTIVE\tC0\tVOLTAGE\tPOWER\tTEMPERATURE")
+	for core := 0; core < t.Cores(); core++ {
+		// Like ryzen_monitor, leave out the cores fused off.
+		if !t.CoreEnabled(core) {
+			continue
+		}
+		fields := []struct {
+			field  pmtable.CoreField
+			format string
+		}{
+			{pmtable.CoreFrequency, "%.0f MHz"},
+			{pmtable.CoreEffectiveFrequency, "%.0f MHz"},
+			{pmtable.CoreC0, "%.1f%%"},
+			{pmtable.CoreVoltage, "%.3f V"},
+			{pmtable.CorePower, "%.2f W"},
+			{pmtable.CoreTemperature, "%.1f °C"},
+		}
+		values := make([]string, len(fields))
+		for i, f := range fields {
+			values[i] = "unavailable"
+			if v, err := t.GetCore(f.field, core); err == nil {
+				values[i] = fmt.Sprintf(f.format, v)
+			}
+		}
+		fmt.Fprintf(w, "%d\t%s\n", core, strings.Join(values, "\t"))
+	}
+	w.Flush()
+}
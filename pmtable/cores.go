@@ -0,0 +1,129 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pmtable
+
+import "fmt"
+
+// CoreField is a known per-core entry of the PM table, which has one value
+// for each physical core, in a row.
+type CoreField int
+
+// Known per-core fields. The frequency is the one the core runs at when
+// active, and the effective frequency the one it actually delivered, which is
+// lower when the core sleeps or its clock is stretched, e.g. because the
+// voltage is too low for the frequency. Frequencies are in MHz, the voltage in
+// volts, the power in watts, the temperature in °C and the C0 residency, i.e.
+// the time spent active, in percent.
+const (
+	CorePower CoreField = iota
+	CoreVoltage
+	CoreTemperature
+	CoreFrequency
+	CoreEffectiveFrequency
+	CoreC0
+)
+
+var (
+	coreFieldNames = map[CoreField]string{
+		CorePower:              "CORE_POWER",
+		CoreVoltage:            "CORE_VOLTAGE",
+		CoreTemperature:        "CORE_TEMP",
+		CoreFrequency:          "CORE_FREQ",
+		CoreEffectiveFrequency: "CORE_FREQEFF",
+		CoreC0:                 "CORE_C0",
+	}
+
+	// coreLayouts has, for each known PM table version, the number of
+	// cores the table has values for, and the index of the value of the
+	// first core of each known per-core field. Also obtained from the
+	// ryzen_monitor project.
+	coreLayouts = map[uint32]coreLayout{
+		0x240903: {
+			cores: 8,
+			first: map[CoreField]int{
+				CorePower:              200,
+				CoreVoltage:            208,
+				CoreTemperature:        216,
+				CoreFrequency:          240,
+				CoreEffectiveFrequency: 248,
+				CoreC0:                 256,
+			},
+		},
+	}
+)
+
+// coreLayout is where the per-core fields are in a version of the PM table.
+type coreLayout struct {
+	cores int
+	first map[CoreField]int
+}
+
+// String returns the name of the field, as used by ryzen_monitor.
+func (f CoreField) String() string {
+	if name, ok := coreFieldNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown (%d)", int(f))
+}
+
+// Cores returns how many cores the table has per-core values for, or 0 if
+// the per-core layout of the table is not known.
+func (t *Table) Cores() int {
+	return coreLayouts[t.Version].cores
+}
+
+// GetCore returns the value of the given per-core field for the given
+// physical core, counted from 0. It returns ErrUnsupported if the per-core
+// layout of the table is not known.
+func (t *Table) GetCore(f CoreField, core int) (float64, error) {
+	layout, ok := coreLayouts[t.Version]
+	if !ok {
+		return 0, ErrUnsupported
+	}
+	first, ok := layout.first[f]
+	if !ok {
+		return 0, ErrUnsupported
+	}
+	if core < 0 || core >= layout.cores {
+		return 0, fmt.Errorf("core %d out of range; the PM table has %d cores", core, layout.cores)
+	}
+	index := first + core
+	if index >= len(t.Entries) {
+		return 0, fmt.Errorf("PM table too short: %d entries", len(t.Entries))
+	}
+	value := float64(t.Entries[index])
+	if f == CoreFrequency || f == CoreEffectiveFrequency {
+		// The table has them in GHz.
+		value *= 1000
+	}
+	return value, nil
+}
+
+// CoreEnabled returns whether the given physical core is enabled. The table
+// has values for every core of each CCD, including the ones fused off, e.g.
+// two of the eight of each CCD of 6-core processors, which are all zero, as
+// nothing is tracked for them; an enabled core, even asleep, at least has a
+// temperature.
+func (t *Table) CoreEnabled(core int) bool {
+	layout, ok := coreLayouts[t.Version]
+	if !ok {
+		return false
+	}
+	for f := range layout.first {
+		if v, err := t.GetCore(f, core); err == nil && v != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pmtable
+
+import (
+	"fmt"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/smu"
+)
+
+// Field is a known entry of the SMU power management (PM) table, whose index
+// in the table depends on its version.
+type Field int
+
+// Known fields, each a limit and the matching current value. PPT is the
+// package power, in watts, TDC and EDC the sustained and peak current, in
+// amperes, THM the temperature, in °C, and FIT the reliability budget the
+// firmware tracks, in percent. SOCTemp is the temperature of the SoC, i.e.
+// the I/O die (IOD) of chiplet processors, in °C, which is not part of Tctl.
+const (
+	PPTLimit Field = iota
+	PPTValue
+	TDCLimit
+	TDCValue
+	THMLimit
+	THMValue
+	FITLimit
+	FITValue
+	EDCLimit
+	EDCValue
+	SOCTemp
+)
+
+var (
+	fieldNames = map[Field]string{
+		PPTLimit: "PPT_LIMIT",
+		PPTValue: "PPT_VALUE",
+		TDCLimit: "TDC_LIMIT",
+		TDCValue: "TDC_VALUE",
+		THMLimit: "THM_LIMIT",
+		THMValue: "THM_VALUE",
+		FITLimit: "FIT_LIMIT",
+		FITValue: "FIT_VALUE",
+		EDCLimit: "EDC_LIMIT",
+		EDCValue: "EDC_VALUE",
+		SOCTemp:  "SOC_TEMP",
+	}
+
+	// zen2Layout is the start of the table of Matisse and Vermeer, which
+	// share it. Layouts obtained from the ryzen_monitor project available at
+	// https://gitlab.com/leogx9r/ryzen_monitor.
+	zen2Layout = map[Field]int{
+		PPTLimit: 0,
+		PPTValue: 1,
+		TDCLimit: 2,
+		TDCValue: 3,
+		THMLimit: 4,
+		THMValue: 5,
+		FITLimit: 6,
+		FITValue: 7,
+		EDCLimit: 8,
+		EDCValue: 9,
+	}
+
+	// layouts has the index of the known fields for each known PM table
+	// version. A field missing from the layout of a version, e.g. SOCTemp,
+	// whose index differs between versions and is not verified for these,
+	// is reported as unsupported.
+	layouts = map[uint32]map[Field]int{
+		// Matisse.
+		0x240802: zen2Layout,
+		0x240803: zen2Layout,
+		0x240902: zen2Layout,
+		0x240903: zen2Layout,
+		// Vermeer.
+		0x380804: zen2Layout,
+		0x380805: zen2Layout,
+		0x380904: zen2Layout,
+		0x380905: zen2Layout,
+	}
+
+	// ErrUnsupported is returned when the layout of the PM table is not
+	// known for its version. It is the same as smu.ErrUnsupported.
+	ErrUnsupported = smu.ErrUnsupported
+)
+
+// String returns the name of the field, as used by ryzen_monitor.
+func (f Field) String() string {
+	if name, ok := fieldNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown (%d)", int(f))
+}
+
+// Table is a copy of the PM table.
+type Table struct {
+	// Version determines the layout of the table.
+	Version uint32
+	// Entries are the values in the table, which are all 32-bit floats.
+	Entries []float32
+}
+
+// Read returns a copy of the current PM table, which requires the ryzen_smu
+// module.
+func Read() (*Table, error) {
+	version, err := smu.PMTableVersion()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := smu.PMTable()
+	if err != nil {
+		return nil, err
+	}
+	return &Table{Version: version, Entries: entries}, nil
+}
+
+// Known returns whether the layout of the table is known, i.e. whether Get
+// can find its fields.
+func (t *Table) Known() bool {
+	_, ok := layouts[t.Version]
+	return ok
+}
+
+// Get returns the value of the given field. It returns ErrUnsupported if the
+// layout of the table is not known.
+func (t *Table) Get(f Field) (float64, error) {
+	index, ok := layouts[t.Version][f]
+	if !ok {
+		return 0, ErrUnsupported
+	}
+	if index >= len(t.Entries) {
+		return 0, fmt.Errorf("PM table too short: %d entries", len(t.Entries))
+	}
+	return float64(t.Entries[index]), nil
+}
+
+// FieldAt returns the known field at the given index of the table, and
+// whether there is one.
+func (t *Table) FieldAt(index int) (Field, bool) {
+	for f, i := range layouts[t.Version] {
+		if i == index {
+			return f, true
+		}
+	}
+	return 0, false
+}
+
+// Value reads the PM table and returns the value of the given field. It
+// returns ErrUnsupported if the layout of the table is not known.
+func Value(f Field) (float64, error) {
+	t, err := Read()
+	if err != nil {
+		return 0, err
+	}
+	return t.Get(f)
+}
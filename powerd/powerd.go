@@ -0,0 +1,87 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package powerd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// daemon describes how to detect a power management daemon.
+type daemon struct {
+	// name is the name of the daemon, for display.
+	name string
+	// comm is the process name, as in /proc/<pid>/comm, which the kernel
+	// truncates to 15 characters.
+	comm string
+	// unit is a systemd unit that, when enabled, runs the daemon or applies
+	// its settings at boot, for the ones that do not keep running.
+	unit string
+}
+
+var (
+	// daemons are the power management daemons known to change processor
+	// boosting, the cpufreq governor or similar settings.
+	daemons = []daemon{
+		{name: "tuned", comm: "tuned"},
+		{name: "power-profiles-daemon", comm: "power-profiles-"},
+		{name: "auto-cpufreq", comm: "auto-cpufreq"},
+		// TLP applies its settings and exits, so it is not found running.
+		{name: "TLP", unit: "tlp.service"},
+	}
+
+	// unitDirs are where enabled systemd units are linked from.
+	unitDirs = []string{"/etc/systemd/system/multi-user.target.wants", "/etc/systemd/system/graphical.target.wants"}
+)
+
+// runningProcesses returns the names of the running processes.
+func runningProcesses() map[string]bool {
+	names := map[string]bool{}
+	comms, err := filepath.Glob("/proc/[0-9]*/comm")
+	if err != nil {
+		return names
+	}
+	for _, c := range comms {
+		// Processes may exit in the meantime, so errors are expected.
+		if comm, err := ioutil.ReadFile(c); err == nil {
+			names[strings.TrimSpace(string(comm))] = true
+		}
+	}
+	return names
+}
+
+// unitEnabled returns whether the given systemd unit is enabled.
+func unitEnabled(unit string) bool {
+	for _, dir := range unitDirs {
+		if _, err := os.Stat(filepath.Join(dir, unit)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Conflicting returns the power management daemons found running, or enabled,
+// that may revert the settings changed by ryzen-stabilizator.
+func Conflicting() []string {
+	processes := runningProcesses()
+	var found []string
+	for _, d := range daemons {
+		if (d.comm != "" && processes[d.comm]) || (d.unit != "" && unitEnabled(d.unit)) {
+			found = append(found, d.name)
+		}
+	}
+	return found
+}
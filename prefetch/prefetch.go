@@ -0,0 +1,103 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prefetch
+
+import (
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/cpuinfo"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/setting"
+)
+
+const (
+	// prefetchControlMSR is the PrefetchControl MSR, documented in the AMD64
+	// Architecture Programmer's Manual for family 19h processors. Each bit
+	// set disables the matching hardware prefetcher.
+	prefetchControlMSR = 0xC0000108
+
+	// The only family with PrefetchControl documented, i.e. Zen 3 and
+	// Zen 4; others are not assumed to have the same bits.
+	amdZen3Family = 0x19
+)
+
+const (
+	l1Stream = 1 << 0
+	l1Stride = 1 << 1
+	l1Region = 1 << 2
+	l2Stream = 1 << 3
+	l2UpDown = 1 << 5
+
+	// l1Bits are the bits disabling the L1 data cache prefetchers.
+	l1Bits = l1Stream | l1Stride | l1Region
+	// l2Bits are the bits disabling the L2 cache prefetchers.
+	l2Bits = l2Stream | l2UpDown
+)
+
+var (
+	// ErrUnsupported is wrapped by the error returned when the prefetcher
+	// control bits are not documented for the running processor family. It
+	// is the same as cpuinfo.ErrFamilyUnsupported.
+	ErrUnsupported = cpuinfo.ErrFamilyUnsupported
+)
+
+// Supported returns nil if the prefetcher control bits are documented for the
+// running processor family, or a cpuinfo.FamilyError otherwise.
+func Supported() error {
+	return cpuinfo.RequireFamilyIn("prefetcher control", amdZen3Family)
+}
+
+// changePrefetchers enables or disables, in every CPU, the prefetchers of the
+// given setting, depending on whether enabled is true or false, respectively.
+func changePrefetchers(s *setting.MSRBit, enabled bool) error {
+	if err := s.Available(); err != nil {
+		return err
+	}
+	value := setting.Disabled
+	if enabled {
+		value = setting.Enabled
+	}
+	return s.Apply(value)
+}
+
+// prefetchersEnabled returns true if the prefetchers of the given setting are
+// enabled in any CPU.
+func prefetchersEnabled(s *setting.MSRBit) (bool, error) {
+	if err := s.Available(); err != nil {
+		return false, err
+	}
+	status, err := s.Status()
+	if err != nil {
+		return false, err
+	}
+	return status == setting.Enabled, nil
+}
+
+// SetL1 enables or disables the L1 data cache prefetchers (stream, stride and
+// region).
+func SetL1(enabled bool) error {
+	return changePrefetchers(l1Setting, enabled)
+}
+
+// SetL2 enables or disables the L2 cache prefetchers (stream and up/down).
+func SetL2(enabled bool) error {
+	return changePrefetchers(l2Setting, enabled)
+}
+
+// L1Enabled returns true if the L1 data cache prefetchers are enabled.
+func L1Enabled() (bool, error) {
+	return prefetchersEnabled(l1Setting)
+}
+
+// L2Enabled returns true if the L2 cache prefetchers are enabled.
+func L2Enabled() (bool, error) {
+	return prefetchersEnabled(l2Setting)
+}
@@ -0,0 +1,53 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prefetch
+
+import (
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/setting"
+)
+
+var (
+	// The bits disable the prefetchers, so they are set to disable them.
+	l1Setting = &setting.MSRBit{
+		Toggle: setting.Toggle{
+			Key:          "prefetchl1",
+			Label:        "L1 hardware prefetchers",
+			Availability: Supported,
+			BootDefault:  setting.Enabled,
+			Order:        setting.OrderPrefetch,
+		},
+		Register: prefetchControlMSR,
+		Bits:     l1Bits,
+		PerCore:  true,
+		SetMeans: setting.Disabled,
+	}
+	l2Setting = &setting.MSRBit{
+		Toggle: setting.Toggle{
+			Key:          "prefetchl2",
+			Label:        "L2 hardware prefetchers",
+			Availability: Supported,
+			BootDefault:  setting.Enabled,
+			Order:        setting.OrderPrefetch,
+		},
+		Register: prefetchControlMSR,
+		Bits:     l2Bits,
+		PerCore:  true,
+		SetMeans: setting.Disabled,
+	}
+)
+
+func init() {
+	setting.Register(l1Setting)
+	setting.Register(l2Setting)
+}
@@ -0,0 +1,80 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// prometheusLabel escapes value for use as a label value in the Prometheus
+// text exposition format.
+func prometheusLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// prometheusBool converts a boolean to a sample value.
+func prometheusBool(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// prometheusStatus prints the status of every setting in the Prometheus text
+// exposition format, e.g. for the textfile collector of node_exporter. With a
+// config file, whether each configured setting matches it is included, but
+// nothing is applied. It returns the exit code.
+func prometheusStatus(configFile, configDir string) int {
+	if err := sanityCheck(false); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v.\n", err)
+		return 1
+	}
+	var cfg *configuration
+	if configFile != "" || configDir != "" {
+		var err error
+		if cfg, err = loadConfiguration(configFile, configDir); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v.\n", err)
+			return 1
+		}
+	}
+	comparisons := compareSettings(cfg)
+
+	fmt.Println("# HELP ryzen_stabilizator_setting_available Whether the setting can be managed on this machine.")
+	fmt.Println("# TYPE ryzen_stabilizator_setting_available gauge")
+	for _, c := range comparisons {
+		fmt.Printf("ryzen_stabilizator_setting_available{setting=\"%s\"} %d\n", prometheusLabel(c.setting.Name()), prometheusBool(c.unavailable == nil))
+	}
+
+	fmt.Println("# HELP ryzen_stabilizator_setting_status Current value of the setting, in the value label.")
+	fmt.Println("# TYPE ryzen_stabilizator_setting_status gauge")
+	for _, c := range comparisons {
+		if c.unavailable == nil && c.err == nil {
+			fmt.Printf("ryzen_stabilizator_setting_status{setting=\"%s\",value=\"%s\"} 1\n", prometheusLabel(c.setting.Name()), prometheusLabel(c.current))
+		}
+	}
+
+	if cfg == nil {
+		return 0
+	}
+	fmt.Println("# HELP ryzen_stabilizator_setting_matches_config Whether the setting has the value in the config file.")
+	fmt.Println("# TYPE ryzen_stabilizator_setting_matches_config gauge")
+	for _, c := range comparisons {
+		if c.configured && c.unavailable == nil && c.err == nil {
+			fmt.Printf("ryzen_stabilizator_setting_matches_config{setting=\"%s\",configured=\"%s\"} %d\n", prometheusLabel(c.setting.Name()), prometheusLabel(c.desired), prometheusBool(!c.mismatch()))
+		}
+	}
+	return 0
+}
@@ -0,0 +1,263 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pstate
+
+import (
+	"fmt"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/cpuinfo"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/cpulist"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/lockdown"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/msr"
+)
+
+const (
+	// firstPStateMSR is the MSR of the software P-state P0; the following
+	// ones hold P1 through P7. Documented in the AMD Processor Programming
+	// Reference (PPR) for family 17h processors, as PStateDef.
+	firstPStateMSR = 0xC0010064
+
+	// Count is the number of software P-states.
+	Count = 8
+
+	enabledBit = 1 << 63
+	vidShift   = 14
+	vidMask    = 0xFF
+	didShift   = 8
+	didMask    = 0x3F
+	fidMask    = 0xFF
+
+	// The families with this P-state layout: 17h, i.e. Zen to Zen 2, and
+	// 19h, i.e. Zen 3 and Zen 4, of which only the SVI2 models are
+	// supported; see svi2Models. Family 1Ah lays the fields out differently.
+	amdZenFamily  = 0x17
+	amdZen3Family = 0x19
+)
+
+// svi2Models are the ranges of family 19h models, the Zen 3 ones, whose VID is
+// the 8-bit SVI2 one handled here: Milan, Vermeer and Cezanne. The others, Zen
+// 3+ and Zen 4 onwards, use SVI3, with another voltage formula and a ninth VID
+// bit, so the voltage limits would mean nothing there.
+var svi2Models = [][2]int{
+	{0x00, 0x0F},
+	{0x20, 0x2F},
+	{0x50, 0x5F},
+}
+
+// Accepted ranges of the P-state fields. The divisor must be even above
+// maxOddDID. The voltage is capped at 1.45 V, i.e. minVID, as anything higher
+// risks damaging the processor; VIDs above maxVID turn the core voltage off.
+const (
+	minFID    = 0x10
+	minDID    = 0x08
+	maxOddDID = 0x1A
+	maxDID    = 0x30
+	minVID    = 0x10
+	maxVID    = 0xF7
+)
+
+// PState is the definition of a software P-state.
+type PState struct {
+	// Index is the P-state number, e.g. 0 for P0.
+	Index int `json:"index"`
+	// Enabled indicates whether the P-state may be used.
+	Enabled bool `json:"enabled"`
+	// FID is the core frequency ID.
+	FID uint8 `json:"fid"`
+	// DID is the core frequency divisor ID.
+	DID uint8 `json:"did"`
+	// VID is the core voltage ID, for SVI2.
+	VID uint8 `json:"vid"`
+}
+
+// Frequency returns the core frequency of the P-state, in MHz. The divisor is
+// in eighths, so this is FID * 200 / DID.
+func (p PState) Frequency() int {
+	if p.DID == 0 {
+		return 0
+	}
+	return int(p.FID) * 200 / int(p.DID)
+}
+
+// Voltage returns the core voltage of the P-state, in volts. With SVI2, each
+// VID step lowers the voltage by 6.25 mV from 1.55 V.
+func (p PState) Voltage() float64 {
+	return 1.55 - float64(p.VID)*0.00625
+}
+
+// encode returns the value of a P-state MSR, original, with the FID, DID and
+// VID replaced by the given ones, and the other fields as they are.
+func encode(original uint64, fid, did, vid uint8) uint64 {
+	fields := uint64(vidMask)<<vidShift | uint64(didMask)<<didShift | fidMask
+	return original&^fields | uint64(vid)<<vidShift | uint64(did)<<didShift | uint64(fid)
+}
+
+// decode decodes the value of a P-state MSR.
+func decode(index int, value uint64) PState {
+	return PState{
+		Index:   index,
+		Enabled: value&enabledBit != 0,
+		FID:     uint8(value & fidMask),
+		DID:     uint8((value >> didShift) & didMask),
+		VID:     uint8((value >> vidShift) & vidMask),
+	}
+}
+
+// Available returns nil if the P-state MSRs can be accessed, or the reason
+// they cannot otherwise.
+func Available() error {
+	if err := msr.Check(); err != nil {
+		return err
+	}
+	if err := cpuinfo.RequireFamilyIn("P-state control", amdZenFamily, amdZen3Family); err != nil {
+		return err
+	}
+	return requireSVI2()
+}
+
+// requireSVI2 returns nil if the processor uses SVI2 voltage IDs, i.e. it is
+// from family 17h or a Zen 3 model of family 19h, or an error otherwise.
+func requireSVI2() error {
+	if cpuinfo.Family() != amdZen3Family {
+		return nil
+	}
+	model := cpuinfo.Model()
+	for _, r := range svi2Models {
+		if model >= r[0] && model <= r[1] {
+			return nil
+		}
+	}
+	return fmt.Errorf("P-state control not supported on processor family %Xh model %02Xh, which uses SVI3 voltage IDs", amdZen3Family, model)
+}
+
+// Read returns the definition of a given software P-state of a given CPU.
+func Read(index, cpu int) (PState, error) {
+	if index < 0 || index >= Count {
+		return PState{}, fmt.Errorf("invalid P-state %d; expected 0-%d", index, Count-1)
+	}
+	value, err := msr.Read(firstPStateMSR+int64(index), cpu)
+	if err != nil {
+		return PState{}, err
+	}
+	return decode(index, value), nil
+}
+
+// Table returns the definitions of every software P-state. They are read from
+// the first CPU, as they are meant to be the same on every core.
+func Table() ([]PState, error) {
+	table := make([]PState, Count)
+	for i := range table {
+		p, err := Read(i, 0)
+		if err != nil {
+			return nil, err
+		}
+		table[i] = p
+	}
+	return table, nil
+}
+
+// Validate returns nil if the given FID, DID and VID are within the accepted
+// ranges, or an error explaining which is not otherwise.
+func Validate(fid, did, vid uint8) error {
+	switch {
+	case fid < minFID:
+		return fmt.Errorf("invalid FID 0x%02X; expected 0x%02X-0x%02X", fid, minFID, fidMask)
+	case did < minDID || did > maxDID:
+		return fmt.Errorf("invalid DID 0x%02X; expected 0x%02X-0x%02X", did, minDID, maxDID)
+	case did > maxOddDID && did%2 != 0:
+		return fmt.Errorf("invalid DID 0x%02X; expected an even DID above 0x%02X", did, maxOddDID)
+	case vid < minVID || vid > maxVID:
+		return fmt.Errorf("invalid VID 0x%02X; expected 0x%02X-0x%02X, i.e. at most 1.45 V", vid, minVID, maxVID)
+	}
+	return nil
+}
+
+// SetPState changes the FID, DID and VID of a given software P-state, in every
+// online CPU, leaving the other fields as they are. Each write is verified by
+// reading it back. The P-state must be enabled. Every CPU is read before
+// writing any, and, if writing one fails, the CPUs already written are
+// restored, so that the CPUs are not left with different definitions of the
+// P-state. For the same reason, the CPUs written are all the online ones,
+// whatever the CPUs per-core operations are limited to.
+func SetPState(index int, fid, did, vid uint8) error {
+	if index < 0 || index >= Count {
+		return fmt.Errorf("invalid P-state %d; expected 0-%d", index, Count-1)
+	}
+	if err := Validate(fid, did, vid); err != nil {
+		return err
+	}
+	if err := requireSVI2(); err != nil {
+		return err
+	}
+	if err := lockdown.CheckMSRWrites(); err != nil {
+		return err
+	}
+
+	offset := firstPStateMSR + int64(index)
+	cpus, err := cpulist.Online()
+	if err != nil {
+		return fmt.Errorf("unable to obtain the online CPUs: %v", err)
+	}
+	original := make([]uint64, len(cpus))
+	for i, c := range cpus {
+		value, err := msr.Read(offset, c)
+		if err != nil {
+			return err
+		}
+		if value&enabledBit == 0 {
+			return fmt.Errorf("P%d is disabled on CPU %d", index, c)
+		}
+		original[i] = value
+	}
+
+	for i, c := range cpus {
+		value := encode(original[i], fid, did, vid)
+		if err := writeVerified(offset, c, value); err != nil {
+			return rollback(index, offset, cpus[:i+1], original, err)
+		}
+	}
+	return nil
+}
+
+// writeVerified writes value to the given MSR of a CPU, and checks it reads
+// back as written.
+func writeVerified(offset int64, cpu int, value uint64) error {
+	if err := msr.Write(offset, cpu, value); err != nil {
+		return err
+	}
+	readBack, err := msr.Read(offset, cpu)
+	if err != nil {
+		return err
+	}
+	if readBack != value {
+		return fmt.Errorf("CPU %d reads back as 0x%016X after writing 0x%016X", cpu, readBack, value)
+	}
+	return nil
+}
+
+// rollback restores the original definition of P-state index on cpus, after
+// writing one of them failed with err, which it returns along with the CPUs
+// that could not be restored, if any.
+func rollback(index int, offset int64, cpus []int, original []uint64, err error) error {
+	var failed []int
+	for i, c := range cpus {
+		if restoreErr := writeVerified(offset, c, original[i]); restoreErr != nil {
+			failed = append(failed, c)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("unable to set P%d: %v; unable to restore it on CPUs %v either", index, err, failed)
+	}
+	return fmt.Errorf("unable to set P%d: %v; restored the original definition", index, err)
+}
@@ -0,0 +1,80 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pstate
+
+import (
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		fid, did, vid uint8
+		valid         bool
+	}{
+		{0x90, 0x08, 0x48, true},
+		{minFID, minDID, minVID, true},
+		{fidMask, maxDID, maxVID, true},
+		{0x90, maxOddDID, 0x48, true},
+		{0x90, maxOddDID + 2, 0x48, true},
+		{minFID - 1, 0x08, 0x48, false},
+		{0x90, minDID - 1, 0x48, false},
+		{0x90, maxDID + 1, 0x48, false},
+		{0x90, maxOddDID + 1, 0x48, false},
+		{0x90, 0x08, minVID - 1, false},
+		{0x90, 0x08, maxVID + 1, false},
+	}
+	for _, tt := range tests {
+		err := Validate(tt.fid, tt.did, tt.vid)
+		if (err == nil) != tt.valid {
+			t.Errorf("Validate(0x%02X, 0x%02X, 0x%02X) = %v, expected valid %v", tt.fid, tt.did, tt.vid, err, tt.valid)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		original      uint64
+		fid, did, vid uint8
+		want          uint64
+	}{
+		// Enabled, with the other fields kept.
+		{0x8000000000000000, 0x90, 0x08, 0x48, 0x8000000000120890},
+		{0x80000000491A0888, 0x90, 0x08, 0x48, 0x8000000049120890},
+		{0xFFFFFFFFFFFFFFFF, 0x00, 0x00, 0x00, 0xFFFFFFFFFFC00000},
+	}
+	for _, tt := range tests {
+		got := encode(tt.original, tt.fid, tt.did, tt.vid)
+		if got != tt.want {
+			t.Errorf("encode(0x%016X, 0x%02X, 0x%02X, 0x%02X) = 0x%016X, expected 0x%016X", tt.original, tt.fid, tt.did, tt.vid, got, tt.want)
+		}
+		p := decode(0, got)
+		if p.FID != tt.fid || p.DID != tt.did || p.VID != tt.vid {
+			t.Errorf("decode(0x%016X) = %+v, expected FID 0x%02X, DID 0x%02X and VID 0x%02X", got, p, tt.fid, tt.did, tt.vid)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	p := decode(1, 0x8000000000120890)
+	if !p.Enabled || p.Index != 1 {
+		t.Errorf("decode() = %+v, expected enabled P1", p)
+	}
+	if f := p.Frequency(); f != 3600 {
+		t.Errorf("Frequency() = %d, expected 3600", f)
+	}
+	// 1.55 V - 0x48 * 6.25 mV.
+	if v := p.Voltage(); v < 1.099 || v > 1.101 {
+		t.Errorf("Voltage() = %.4f, expected 1.1000", v)
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pstate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/setting"
+)
+
+const (
+	// configurable is how many P-states, from P0, can be set in the config
+	// file. The others are rarely defined.
+	configurable = 3
+
+	// disabled is the status of a P-state that is not enabled.
+	disabled = "disabled"
+)
+
+func init() {
+	for i := 0; i < configurable; i++ {
+		setting.Register(&pstateSetting{index: i})
+	}
+}
+
+// pstateSetting is the setting with the FID, DID and VID of a software
+// P-state. Its value is the three of them, separated by commas, e.g.
+// "0x90,0x08,0x48".
+type pstateSetting struct {
+	index int
+}
+
+// Name returns the key of the setting in the config file, e.g. "pstate0".
+func (p *pstateSetting) Name() string {
+	return fmt.Sprintf("pstate%d", p.index)
+}
+
+// Description returns the human-readable name of the setting.
+func (p *pstateSetting) Description() string {
+	return fmt.Sprintf("P-state P%d", p.index)
+}
+
+// Values returns a description of the values accepted in the config file, as
+// they cannot be listed.
+func (p *pstateSetting) Values() []string {
+	return []string{"FID,DID,VID, e.g. 0x90,0x08,0x48"}
+}
+
+// Available reports whether the P-state MSRs can be accessed.
+func (p *pstateSetting) Available() error {
+	return Available()
+}
+
+// parse parses a FID, DID and VID, separated by commas, each in decimal or
+// hex.
+func parse(value string) (fid, did, vid uint8, err error) {
+	fields := strings.Split(value, ",")
+	if len(fields) != 3 {
+		return 0, 0, 0, fmt.Errorf("invalid P-state %q; expected FID,DID,VID", value)
+	}
+	var ids [3]uint8
+	for i, f := range fields {
+		id, err := strconv.ParseUint(strings.TrimSpace(f), 0, 8)
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid P-state %q; expected FID,DID,VID", value)
+		}
+		ids[i] = uint8(id)
+	}
+	return ids[0], ids[1], ids[2], nil
+}
+
+// format formats a FID, DID and VID the way Status reports them.
+func format(fid, did, vid uint8) string {
+	return fmt.Sprintf("0x%02X,0x%02X,0x%02X", fid, did, vid)
+}
+
+// Validate checks value has a FID, DID and VID within the accepted ranges.
+func (p *pstateSetting) Validate(value string) error {
+	fid, did, vid, err := parse(value)
+	if err != nil {
+		return err
+	}
+	return Validate(fid, did, vid)
+}
+
+// Normalize returns value in the format returned by Status, so that they can
+// be compared.
+func (p *pstateSetting) Normalize(value string) string {
+	fid, did, vid, err := parse(value)
+	if err != nil {
+		return strings.ToLower(value)
+	}
+	return format(fid, did, vid)
+}
+
+// Apply changes the P-state to the FID, DID and VID in value.
+func (p *pstateSetting) Apply(value string) error {
+	fid, did, vid, err := parse(value)
+	if err != nil {
+		return err
+	}
+	return SetPState(p.index, fid, did, vid)
+}
+
+// Status returns the FID, DID and VID of the P-state on the first CPU, or
+// "disabled".
+func (p *pstateSetting) Status() (string, error) {
+	ps, err := Read(p.index, 0)
+	if err != nil {
+		return "", err
+	}
+	if !ps.Enabled {
+		return disabled, nil
+	}
+	return format(ps.FID, ps.DID, ps.VID), nil
+}
+
+// Mechanism describes the MSR with the definition of the P-state.
+func (p *pstateSetting) Mechanism() string {
+	return fmt.Sprintf("MSR 0x%X bits 0 to 21, on every CPU", firstPStateMSR+p.index)
+}
+
+// NeedsConfirmation returns true, as a wrong P-state can crash the machine.
+func (p *pstateSetting) NeedsConfirmation(value string) bool {
+	return true
+}
+
+// NeedsForce returns true, as a wrong voltage can keep the machine from
+// booting, or even damage it, so the P-states are never changed without an
+// explicit confirmation.
+func (p *pstateSetting) NeedsForce(value string) bool {
+	return true
+}
+
+// ApplyOrder returns OrderPStates, so that the P-states are changed after
+// boosting, which may use them.
+func (p *pstateSetting) ApplyOrder() int {
+	return setting.OrderPStates
+}
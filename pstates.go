@@ -0,0 +1,70 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/pstate"
+)
+
+// pstateInfo is a software P-state, as included in the JSON output of
+// -pstates.
+type pstateInfo struct {
+	pstate.PState
+	FrequencyMHz int     `json:"frequency_mhz,omitempty"`
+	Voltage      float64 `json:"voltage,omitempty"`
+}
+
+// showPStates displays the frequency and voltage of each enabled software
+// P-state, along with the raw FID, DID and VID, or all of them as JSON.
+func showPStates() error {
+	if err := pstate.Available(); err != nil {
+		return err
+	}
+	table, err := pstate.Table()
+	if err != nil {
+		return fmt.Errorf("unable to read P-states: %v", err)
+	}
+
+	if jsonOutput {
+		infos := make([]pstateInfo, len(table))
+		for i, p := range table {
+			infos[i] = pstateInfo{PState: p}
+			if p.Enabled {
+				infos[i].FrequencyMHz = p.Frequency()
+				infos[i].Voltage = p.Voltage()
+			}
+		}
+		buf, err := json.MarshalIndent(infos, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(buf))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "P-STATE\tFREQUENCY\tVOLTAGE\tFID\tDID\tVID")
+	for _, p := range table {
+		if !p.Enabled {
+			continue
+		}
+		fmt.Fprintf(w, "P%d\t%d MHz\t%.4f V\t0x%02X\t0x%02X\t0x%02X\n", p.Index, p.Frequency(), p.Voltage(), p.FID, p.DID, p.VID)
+	}
+	return w.Flush()
+}
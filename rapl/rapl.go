@@ -0,0 +1,117 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rapl
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// powercapDir has the RAPL (Running Average Power Limit) zones. The
+	// kernel exposes AMD processors through the same intel-rapl interface.
+	powercapDir = "/sys/class/powercap"
+)
+
+var (
+	// ErrNoPackage is returned when there is no RAPL package zone.
+	ErrNoPackage = errors.New("no RAPL package zone")
+)
+
+// packageZones returns the RAPL package zones, one per socket.
+func packageZones() ([]string, error) {
+	matches, err := filepath.Glob(powercapDir + "/intel-rapl:[0-9]*")
+	if err != nil {
+		return nil, err
+	}
+	var zones []string
+	for _, m := range matches {
+		// Subzones, e.g. intel-rapl:0:0, are the cores, not the package.
+		if strings.Count(filepath.Base(m), ":") != 1 {
+			continue
+		}
+		name, err := ioutil.ReadFile(m + "/name")
+		if err != nil || !strings.HasPrefix(strings.TrimSpace(string(name)), "package") {
+			continue
+		}
+		zones = append(zones, m)
+	}
+	if len(zones) == 0 {
+		return nil, ErrNoPackage
+	}
+	return zones, nil
+}
+
+// Available returns a boolean indicating whether the package energy can be
+// read through RAPL.
+func Available() bool {
+	zones, err := packageZones()
+	if err != nil {
+		return false
+	}
+	_, err = readCounter(zones[0] + "/energy_uj")
+	return err == nil
+}
+
+// readCounter reads one of the RAPL counters of a zone.
+func readCounter(file string) (uint64, error) {
+	value, err := ioutil.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(value)), 10, 64)
+}
+
+// PackagePower samples the package energy counters over the given interval
+// and returns the average package power, in watts, summed across sockets.
+func PackagePower(interval time.Duration) (float64, error) {
+	zones, err := packageZones()
+	if err != nil {
+		return 0, err
+	}
+
+	before := make([]uint64, len(zones))
+	for i, z := range zones {
+		if before[i], err = readCounter(z + "/energy_uj"); err != nil {
+			return 0, err
+		}
+	}
+	start := time.Now()
+
+	time.Sleep(interval)
+
+	var joules float64
+	for i, z := range zones {
+		after, err := readCounter(z + "/energy_uj")
+		if err != nil {
+			return 0, err
+		}
+		energy := after - before[i]
+		if after < before[i] {
+			// The counter wrapped around.
+			limit, err := readCounter(z + "/max_energy_range_uj")
+			if err != nil {
+				return 0, fmt.Errorf("energy counter wrapped: %v", err)
+			}
+			energy = limit - before[i] + after
+		}
+		joules += float64(energy) / 1e6
+	}
+	return joules / time.Since(start).Seconds(), nil
+}
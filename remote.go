@@ -0,0 +1,103 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	// configFetchTimeout bounds fetching the config file from a URL.
+	configFetchTimeout = 10 * time.Second
+
+	// configInsecure disables TLS certificate verification when fetching the
+	// config file from a HTTPS URL.
+	configInsecure = false
+
+	// configCacheDir has the last config file successfully fetched from each
+	// URL, to fall back to if fetching fails, e.g. at boot, before the
+	// network is up.
+	configCacheDir = "/var/cache/ryzen-stabilizator"
+)
+
+// isURL returns whether the config file was given as an HTTP(S) URL.
+func isURL(configFile string) bool {
+	return strings.HasPrefix(configFile, "http://") || strings.HasPrefix(configFile, "https://")
+}
+
+// configCacheFile returns the file caching the config fetched from url. Its
+// extension is that of the format of url, so that the cached copy is parsed
+// the same way.
+func configCacheFile(url string) string {
+	return filepath.Join(configCacheDir, fmt.Sprintf("%x.%s", sha256.Sum256([]byte(url)), configFormatFor(url)))
+}
+
+// fetchConfiguration fetches the contents of the config file at url.
+func fetchConfiguration(url string) ([]byte, error) {
+	client := &http.Client{Timeout: configFetchTimeout}
+	if configInsecure {
+		client.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status %q", resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// readRemoteConfiguration fetches and parses the config file at url, caching
+// it if successful. If either fails, the last cached copy is used instead,
+// with a warning.
+func (c *configuration) readRemoteConfiguration(url string) (rsSettings, error) {
+	buf, err := fetchConfiguration(url)
+	if err == nil {
+		var settings rsSettings
+		if settings, err = parseConfiguration(url, buf); err == nil {
+			if cacheErr := writeConfigCache(url, buf); cacheErr != nil {
+				c.warnings = append(c.warnings, fmt.Sprintf("unable to cache config file %q: %v", url, cacheErr))
+			}
+			return settings, nil
+		}
+	}
+
+	cache := configCacheFile(url)
+	buf, cacheErr := ioutil.ReadFile(cache)
+	if cacheErr != nil {
+		return nil, fmt.Errorf("unable to fetch config file %q: %v", url, err)
+	}
+	c.warnings = append(c.warnings, fmt.Sprintf("unable to fetch config file %q: %v; using the last known good copy, %q", url, err, cache))
+	return parseConfiguration(cache, buf)
+}
+
+// writeConfigCache caches the config file fetched from url.
+func writeConfigCache(url string, buf []byte) error {
+	if err := os.MkdirAll(configCacheDir, dirMode); err != nil {
+		return explainWriteError(configCacheDir, err)
+	}
+	return writeFile(configCacheFile(url), buf, privateFileMode)
+}
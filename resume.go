@@ -0,0 +1,78 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// suspendStatsFile is the count of successful suspends since boot.
+	suspendStatsFile = "/sys/power/suspend_stats/success"
+
+	// resumePollInterval is how often watchResume checks for a resume.
+	resumePollInterval = time.Second
+
+	// resumeClockJump is how far the wall clock must get ahead of the
+	// monotonic clock, which stops while suspended, between two checks
+	// to tell a resume, when the suspend count is unavailable.
+	resumeClockJump = 5 * time.Second
+)
+
+// suspendCount returns how many times the system was suspended successfully
+// since boot.
+func suspendCount() (int, error) {
+	data, err := ioutil.ReadFile(suspendStatsFile)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// watchResume sends to the returned channel whenever the system resumes from
+// suspend, which firmware often takes as a chance to revert settings, e.g. C6
+// C-state. It polls the suspend count, or, if it is unavailable, looks for a
+// jump of the wall clock ahead of the monotonic clock.
+func watchResume() <-chan struct{} {
+	resumed := make(chan struct{}, 1)
+	go func() {
+		count, countErr := suspendCount()
+		last := time.Now()
+		for range time.Tick(resumePollInterval) {
+			now := time.Now()
+			wasSuspended := false
+			if countErr == nil {
+				if c, err := suspendCount(); err == nil && c != count {
+					count, wasSuspended = c, true
+				}
+			} else {
+				// Round(0) drops the monotonic reading, so the
+				// difference is the one of the wall clock.
+				jump := now.Round(0).Sub(last.Round(0)) - now.Sub(last)
+				wasSuspended = jump > resumeClockJump
+			}
+			last = now
+			if wasSuspended {
+				select {
+				case resumed <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return resumed
+}
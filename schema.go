@@ -0,0 +1,129 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/setting"
+)
+
+// settingSchema returns the JSON Schema of the value of s. Settings whose
+// values can be listed accept them in any of their spellings, e.g. `enable'
+// and `enabled'; the others are described, and checked by -config-check.
+func settingSchema(s setting.Setting) map[string]interface{} {
+	schema := map[string]interface{}{
+		"type":        "string",
+		"description": capitalize(s.Description()) + ".",
+	}
+	if _, ok := s.(setting.Validator); ok {
+		schema["description"] = fmt.Sprintf("%s: %s.", capitalize(s.Description()), strings.Join(s.Values(), ", "))
+		return schema
+	}
+
+	var values []string
+	seen := map[string]bool{}
+	for _, v := range s.Values() {
+		for _, spelling := range []string{v, setting.Normalize(s, v)} {
+			if !seen[spelling] {
+				seen[spelling] = true
+				values = append(values, spelling)
+			}
+		}
+	}
+	schema["enum"] = values
+	return schema
+}
+
+// configSchema returns a JSON Schema describing the config file, generated from
+// the registered settings, along with the options that are not settings.
+func configSchema() map[string]interface{} {
+	properties := map[string]interface{}{
+		strictKey: map[string]interface{}{
+			"type":        "boolean",
+			"description": "Make unknown keys and invalid values fatal.",
+		},
+		minKernelKey: map[string]interface{}{
+			"type":        "string",
+			"description": "Oldest kernel version the settings are meant for, e.g. 6.1.",
+		},
+		postApplyKey: map[string]interface{}{
+			"type":        "string",
+			"description": "Shell command run after applying the settings.",
+		},
+		onDriftKey: map[string]interface{}{
+			"type":        "string",
+			"description": "Shell command run by the daemon when it corrects a setting changed by someone else.",
+		},
+		onDriftURLKey: map[string]interface{}{
+			"type":        "string",
+			"description": "URL the daemon posts to, as JSON, when it corrects a setting changed by someone else.",
+		},
+		"extra": map[string]interface{}{
+			"type":        "object",
+			"description": "Other sysfs files to write, mapping their paths to values.",
+			"propertyNames": map[string]interface{}{
+				"pattern": "^/sys/",
+			},
+			"additionalProperties": map[string]interface{}{
+				"type": []string{"string", "integer"},
+			},
+		},
+		"self": map[string]interface{}{
+			"type":                 "object",
+			"description":          "Scheduling and umask of ryzen-stabilizator itself.",
+			"additionalProperties": false,
+			"properties": map[string]interface{}{
+				"nice": map[string]interface{}{
+					"type":        "integer",
+					"description": "Nice value, from -20 to 19.",
+					"minimum":     -20,
+					"maximum":     19,
+				},
+				"affinity": map[string]interface{}{
+					"type":        "string",
+					"description": "List of CPUs, e.g. 0-3.",
+				},
+				"umask": map[string]interface{}{
+					"type":        "string",
+					"description": "Umask for the files written, in octal, e.g. 022.",
+					"pattern":     "^0?[0-7]{1,3}$",
+				},
+			},
+		},
+	}
+	for _, s := range setting.All() {
+		properties[s.Name()] = settingSchema(s)
+	}
+
+	return map[string]interface{}{
+		"$schema":              "http://json-schema.org/draft-07/schema#",
+		"title":                program + " configuration",
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties":           properties,
+	}
+}
+
+// showConfigSchema prints the JSON Schema of the config file.
+func showConfigSchema() error {
+	buf, err := json.MarshalIndent(configSchema(), "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(buf))
+	return nil
+}
@@ -0,0 +1,201 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"syscall"
+	"unsafe"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/cpulist"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/kernel"
+)
+
+// Config keys for the scheduling of ryzen-stabilizator itself, which can also
+// be given in a [self] table.
+const (
+	selfNiceKey     = "self.nice"
+	selfAffinityKey = "self.affinity"
+	selfUmaskKey    = "self.umask"
+)
+
+// selfSettings are the scheduling settings of ryzen-stabilizator itself, so
+// that it does not contend with other work, e.g. at boot, and the umask for
+// the files it writes. Empty values are left unchanged.
+type selfSettings struct {
+	nice     string
+	affinity string
+	umask    string
+}
+
+// fromConfiguration fills in, from the configuration, the values not given
+// otherwise, i.e. flags override the config file.
+func (s *selfSettings) fromConfiguration(cfg *configuration) {
+	if v, ok := cfg.settings.value(selfNiceKey); ok && s.nice == "" {
+		s.nice = v
+	}
+	if v, ok := cfg.settings.value(selfAffinityKey); ok && s.affinity == "" {
+		s.affinity = v
+	}
+	if v, ok := cfg.settings.value(selfUmaskKey); ok && s.umask == "" {
+		s.umask = v
+	}
+}
+
+// forEachThread calls fn for every thread of this process. Both the nice
+// value and the affinity are per thread on Linux, and the Go runtime may
+// already be running several, so changing only the calling one would not be
+// enough.
+func forEachThread(fn func(tid int) error) error {
+	tasks, err := ioutil.ReadDir("/proc/self/task")
+	if err != nil {
+		return err
+	}
+	for _, t := range tasks {
+		tid, err := strconv.Atoi(t.Name())
+		if err != nil {
+			continue
+		}
+		if err := fn(tid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cpuMask is a CPU set, as used by sched_setaffinity(2).
+type cpuMask [16]uint64
+
+// setThreadAffinity restricts the given thread to the given CPUs.
+func setThreadAffinity(tid int, cpus []int) error {
+	var mask cpuMask
+	for _, c := range cpus {
+		if c >= len(mask)*64 {
+			return fmt.Errorf("CPU %d out of range", c)
+		}
+		mask[c/64] |= 1 << uint(c%64)
+	}
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY, uintptr(tid), unsafe.Sizeof(mask), uintptr(unsafe.Pointer(&mask[0])))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// threadAffinity returns the CPUs the given thread may run on.
+func threadAffinity(tid int) ([]int, error) {
+	var mask cpuMask
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_GETAFFINITY, uintptr(tid), unsafe.Sizeof(mask), uintptr(unsafe.Pointer(&mask[0])))
+	if errno != 0 {
+		return nil, errno
+	}
+	var cpus []int
+	for c := 0; c < len(mask)*64; c++ {
+		if mask[c/64]&(1<<uint(c%64)) != 0 {
+			cpus = append(cpus, c)
+		}
+	}
+	return cpus, nil
+}
+
+// setAffinity restricts every thread of this process to the given CPUs.
+func setAffinity(cpus []int) error {
+	return forEachThread(func(tid int) error {
+		return setThreadAffinity(tid, cpus)
+	})
+}
+
+// setNice sets the nice value of every thread of this process.
+func setNice(nice int) error {
+	return forEachThread(func(tid int) error {
+		return syscall.Setpriority(syscall.PRIO_PROCESS, tid, nice)
+	})
+}
+
+// parseNice parses a nice value, which must be in the range accepted by
+// setpriority(2).
+func parseNice(value string) (int, error) {
+	nice, err := strconv.Atoi(value)
+	if err != nil || nice < -20 || nice > 19 {
+		return 0, fmt.Errorf("invalid nice value %q; expected -20 to 19", value)
+	}
+	return nice, nil
+}
+
+// parseUmask parses a umask, in octal, e.g. 022 or 0077.
+func parseUmask(value string) (int, error) {
+	umask, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || umask > 0777 {
+		return 0, fmt.Errorf("invalid umask %q; expected an octal value up to 0777, e.g. 022", value)
+	}
+	return int(umask), nil
+}
+
+// setUmask is the umask last set: the one at start, read while initializing
+// the package, before any file is created, or the one set by apply.
+var setUmask = startUmask()
+
+// startUmask returns the umask this process was started with. It can only be
+// read by setting it, which is safe only before other goroutines create files,
+// so it is restored right away.
+func startUmask() int {
+	umask := syscall.Umask(0)
+	syscall.Umask(umask)
+	return umask
+}
+
+// currentUmask returns the umask of this process, from /proc/self/status, or,
+// on kernels before 4.7, which do not have it there, the one last set.
+func currentUmask() int {
+	if umask, err := kernel.Umask(); err == nil {
+		return umask
+	}
+	return setUmask
+}
+
+// apply sets the scheduling priority, CPU affinity and umask of this process,
+// as requested.
+func (s *selfSettings) apply() error {
+	if s.nice != "" {
+		nice, err := parseNice(s.nice)
+		if err != nil {
+			return err
+		}
+		if err := setNice(nice); err != nil {
+			return fmt.Errorf("unable to set nice value %d: %v", nice, err)
+		}
+	}
+
+	if s.affinity != "" {
+		cpus, err := cpulist.Parse(s.affinity)
+		if err != nil {
+			return fmt.Errorf("invalid affinity: %v", err)
+		}
+		if err := setAffinity(cpus); err != nil {
+			return fmt.Errorf("unable to set affinity to %q: %v", s.affinity, err)
+		}
+	}
+
+	if s.umask != "" {
+		umask, err := parseUmask(s.umask)
+		if err != nil {
+			return err
+		}
+		syscall.Umask(umask)
+		setUmask = umask
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setting
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/cpulist"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/lockdown"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/msr"
+)
+
+// MSRBit is a Toggle controlled by one or more bits of a model-specific
+// register, e.g. the hardware prefetchers, so that such settings share the
+// same read-modify-write logic. Other bits in the register are preserved, and
+// every write is read back to verify it.
+//
+// The Enable, Disable and IsEnabled fields of the embedded Toggle are not
+// used; Availability defaults to msr.Check, and Touches to a description of
+// the bits.
+type MSRBit struct {
+	Toggle
+	// Register is the offset of the MSR.
+	Register int64
+	// Bits is the mask of the bits controlling the setting.
+	Bits uint64
+	// PerCore indicates every CPU has its own copy of the register, so it
+	// is changed in all of them. Otherwise, only the one of CPU 0 is.
+	PerCore bool
+	// SetMeans is what having the bits set means, either Enabled or
+	// Disabled, e.g. Disabled for bits that disable a feature.
+	SetMeans string
+	// Mixed, if set, is the status when the CPUs disagree, either Enabled
+	// or Disabled; by default, it is the value meaning the bits are clear.
+	Mixed string
+}
+
+// The MSR accessors and the CPUs per-core operations act on, replaced in
+// tests.
+var (
+	readMSR  = msr.Read
+	writeMSR = msr.Write
+	perCore  = cpulist.CPUs
+)
+
+// cpus returns the CPUs that have their register read and written.
+func (m *MSRBit) cpus() []int {
+	if m.PerCore {
+		return perCore()
+	}
+	return []int{0}
+}
+
+// check returns an error if the setting is not fully defined, which Register
+// reports.
+func (m *MSRBit) check() error {
+	if m.Bits == 0 {
+		return fmt.Errorf("no Bits for %q", m.Key)
+	}
+	if m.SetMeans != Enabled && m.SetMeans != Disabled {
+		return fmt.Errorf("invalid SetMeans %q for %q; expected Enabled or Disabled", m.SetMeans, m.Key)
+	}
+	if m.Mixed != "" && m.Mixed != Enabled && m.Mixed != Disabled {
+		return fmt.Errorf("invalid Mixed %q for %q; expected Enabled or Disabled", m.Mixed, m.Key)
+	}
+	return nil
+}
+
+// Available reports whether the setting can be managed on this machine.
+func (m *MSRBit) Available() error {
+	if err := msr.Check(); err != nil {
+		return err
+	}
+	return m.Toggle.Available()
+}
+
+// set sets or clears the bits in every CPU, depending on whether set is true
+// or false, respectively, verifying each write.
+func (m *MSRBit) set(set bool) error {
+	if err := lockdown.CheckMSRWrites(); err != nil {
+		return err
+	}
+	for _, c := range m.cpus() {
+		value, err := readMSR(m.Register, c)
+		if err != nil {
+			return err
+		}
+		if set {
+			value |= m.Bits
+		} else {
+			value &^= m.Bits
+		}
+		if err = writeMSR(m.Register, c, value); err != nil {
+			return err
+		}
+		current, err := readMSR(m.Register, c)
+		if err != nil {
+			return fmt.Errorf("unable to read back MSR 0x%X of CPU %d: %v", m.Register, c, err)
+		}
+		if current&m.Bits != value&m.Bits {
+			return fmt.Errorf("MSR 0x%X of CPU %d is 0x%X after writing 0x%X", m.Register, c, current, value)
+		}
+	}
+	return nil
+}
+
+// Apply enables or disables the setting.
+func (m *MSRBit) Apply(value string) error {
+	switch m.Normalize(value) {
+	case m.SetMeans:
+		return m.set(true)
+	case Enabled, Disabled:
+		return m.set(false)
+	}
+	return fmt.Errorf("invalid value %q for %s; expected either \"enable\" or \"disable\"", value, m.Key)
+}
+
+// Status returns either Enabled or Disabled. If the CPUs disagree, Mixed is
+// returned or, by default, the value meaning the bits are clear in some of
+// them, since that is the one that was not applied everywhere when SetMeans
+// was.
+func (m *MSRBit) Status() (string, error) {
+	clear := Enabled
+	if m.SetMeans == Enabled {
+		clear = Disabled
+	}
+	set, cleared := false, false
+	for _, c := range m.cpus() {
+		value, err := readMSR(m.Register, c)
+		if err != nil {
+			return "", err
+		}
+		if value&m.Bits == m.Bits {
+			set = true
+		} else {
+			cleared = true
+		}
+	}
+	switch {
+	case set && cleared && m.Mixed != "":
+		return m.Mixed, nil
+	case cleared:
+		return clear, nil
+	}
+	return m.SetMeans, nil
+}
+
+// Mechanism describes the bits of the register controlling the setting.
+func (m *MSRBit) Mechanism() string {
+	if m.Touches != "" {
+		return m.Touches
+	}
+	var bits []string
+	for b := uint(0); b < 64; b++ {
+		if m.Bits&(1<<b) != 0 {
+			bits = append(bits, fmt.Sprint(b))
+		}
+	}
+	where := "on CPU 0"
+	if m.PerCore {
+		where = "on every CPU"
+	}
+	if len(bits) == 1 {
+		return fmt.Sprintf("MSR 0x%X bit %s, %s", m.Register, bits[0], where)
+	}
+	last := len(bits) - 1
+	return fmt.Sprintf("MSR 0x%X bits %s and %s, %s", m.Register, strings.Join(bits[:last], ", "), bits[last], where)
+}
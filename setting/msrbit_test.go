@@ -0,0 +1,142 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setting
+
+import (
+	"testing"
+)
+
+// fakeMSRs replaces the MSR accessors with in-memory registers, one for each
+// of the given CPUs, until the returned function is called. Writes to the
+// CPUs in stuck are ignored, as if the hardware refused them.
+func fakeMSRs(values map[int]uint64, stuck map[int]bool) func() {
+	savedRead, savedWrite, savedCPUs := readMSR, writeMSR, perCore
+	readMSR = func(offset int64, cpu int) (uint64, error) {
+		return values[cpu], nil
+	}
+	writeMSR = func(offset int64, cpu int, value uint64) error {
+		if !stuck[cpu] {
+			values[cpu] = value
+		}
+		return nil
+	}
+	perCore = func() []int {
+		cpus := make([]int, 0, len(values))
+		for c := 0; c < len(values); c++ {
+			cpus = append(cpus, c)
+		}
+		return cpus
+	}
+	return func() {
+		readMSR, writeMSR, perCore = savedRead, savedWrite, savedCPUs
+	}
+}
+
+// testBit is an MSRBit whose bits disable a feature, like the prefetchers.
+func testBit() *MSRBit {
+	return &MSRBit{
+		Toggle:   Toggle{Key: "test"},
+		Register: 0xC0000108,
+		Bits:     0x5,
+		PerCore:  true,
+		SetMeans: Disabled,
+	}
+}
+
+func TestMSRBitApply(t *testing.T) {
+	values := map[int]uint64{0: 0xF0, 1: 0xF0}
+	defer fakeMSRs(values, nil)()
+	m := testBit()
+
+	if err := m.Apply(Disabled); err != nil {
+		t.Fatal(err)
+	}
+	for c, v := range values {
+		if v != 0xF5 {
+			t.Errorf("CPU %d has 0x%X after disabling, expected 0xF5", c, v)
+		}
+	}
+	if status, err := m.Status(); err != nil || status != Disabled {
+		t.Errorf("Status() = %q, %v after disabling, expected %q", status, err, Disabled)
+	}
+
+	if err := m.Apply(Enabled); err != nil {
+		t.Fatal(err)
+	}
+	for c, v := range values {
+		if v != 0xF0 {
+			t.Errorf("CPU %d has 0x%X after enabling, expected 0xF0", c, v)
+		}
+	}
+	if status, err := m.Status(); err != nil || status != Enabled {
+		t.Errorf("Status() = %q, %v after enabling, expected %q", status, err, Enabled)
+	}
+}
+
+func TestMSRBitVerify(t *testing.T) {
+	values := map[int]uint64{0: 0, 1: 0}
+	defer fakeMSRs(values, map[int]bool{1: true})()
+	if err := testBit().Apply(Disabled); err == nil {
+		t.Error("Apply() succeeded, although CPU 1 did not take the write")
+	}
+}
+
+func TestMSRBitDisagree(t *testing.T) {
+	// Only CPU 1 has the feature disabled, so it is not disabled
+	// everywhere.
+	values := map[int]uint64{0: 0, 1: 0x5}
+	defer fakeMSRs(values, nil)()
+	if status, err := testBit().Status(); err != nil || status != Enabled {
+		t.Errorf("Status() = %q, %v, expected %q", status, err, Enabled)
+	}
+}
+
+func TestMSRBitMixed(t *testing.T) {
+	values := map[int]uint64{0: 0, 1: 0x5}
+	defer fakeMSRs(values, nil)()
+	m := testBit()
+	m.Mixed = Disabled
+	if status, err := m.Status(); err != nil || status != Disabled {
+		t.Errorf("Status() = %q, %v, expected %q", status, err, Disabled)
+	}
+	// Agreeing CPUs are not mixed.
+	values[0] = 0x5
+	m.Mixed = Enabled
+	if status, err := m.Status(); err != nil || status != Disabled {
+		t.Errorf("Status() = %q, %v, expected %q", status, err, Disabled)
+	}
+}
+
+func TestMSRBitCheck(t *testing.T) {
+	m := testBit()
+	if err := m.check(); err != nil {
+		t.Errorf("check() = %v, expected nil", err)
+	}
+	for _, means := range []string{"", "on"} {
+		m.SetMeans = means
+		if err := m.check(); err == nil {
+			t.Errorf("check() accepted SetMeans %q", means)
+		}
+	}
+	m = testBit()
+	m.Mixed = "on"
+	if err := m.check(); err == nil {
+		t.Error("check() accepted Mixed \"on\"")
+	}
+	m = testBit()
+	m.Bits = 0
+	if err := m.check(); err == nil {
+		t.Error("check() accepted no Bits")
+	}
+}
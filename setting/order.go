@@ -0,0 +1,57 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setting
+
+// Position of each setting in the order in which settings are applied, and
+// listed, lowest first, so that it does not depend on the order in which the
+// packages implementing them happen to be initialized. Settings give theirs
+// with the Order field of Toggle, Sysfs and MSRBit, or by implementing
+// Orderer. Settings with the same position, only ever from the same package,
+// keep the order in which they are registered.
+const (
+	// The CPUs online come first, so that the settings changed on every
+	// CPU reach the ones left online, and only those.
+	OrderSMT         = 100
+	OrderOnlineCores = 110
+	// C6 comes before its package and core controls, and the Power Supply
+	// Idle Control workaround, which are registered after it.
+	OrderC6          = 200
+	OrderBoosting    = 300
+	OrderPStates     = 310
+	OrderGovernor    = 400
+	OrderPinFreq     = 410
+	OrderIdle        = 420
+	OrderPrefetch    = 500
+	OrderASLR        = 600
+	OrderNMIWatchdog = 610
+	OrderTHP         = 620
+	// OrderLast is the position of the settings that do not give one.
+	OrderLast = 1000
+)
+
+// Orderer is implemented by settings that give their position in the order in
+// which settings are applied.
+type Orderer interface {
+	// ApplyOrder returns the position of the setting, e.g. OrderC6.
+	ApplyOrder() int
+}
+
+// ApplyOrder returns the position of the given setting in the order in which
+// settings are applied, or OrderLast if it does not give one.
+func ApplyOrder(s Setting) int {
+	if o, ok := s.(Orderer); ok && o.ApplyOrder() != 0 {
+		return o.ApplyOrder()
+	}
+	return OrderLast
+}
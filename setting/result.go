@@ -0,0 +1,97 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setting
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNeedsConfirmation is returned, wrapped, by Apply for values that
+	// need to be confirmed, as described in Confirmable and Forcer.
+	ErrNeedsConfirmation = errors.New("needs an explicit confirmation")
+)
+
+// Result is the outcome of applying a value to a setting, for programs using
+// this package to present it as they see fit.
+type Result struct {
+	// Name is the name of the setting.
+	Name string
+	// Requested is the value applied, as given.
+	Requested string
+	// Previous and New are the status of the setting before and after
+	// applying, or empty if it could not be read.
+	Previous string
+	New      string
+	// Changed is whether the value was applied, and the setting did not
+	// already have it, as far as its status tells.
+	Changed bool
+	// Err is why the value could not be applied, if that is the case.
+	Err error
+}
+
+// Apply applies value to the given setting, if it is available and value is
+// valid, and returns the outcome. The value is applied even if the setting
+// already has it, as its status might not reflect every core. Values that
+// need to be confirmed, e.g. P-state changes, are not applied, failing with
+// ErrNeedsConfirmation; once confirmed, apply them with ApplyConfirmed.
+func Apply(s Setting, value string) Result {
+	c, ok := s.(Confirmable)
+	if NeedsForce(s, value) || ok && c.NeedsConfirmation(value) {
+		return Result{Name: s.Name(), Requested: value, Err: fmt.Errorf("%s %q: %w", s.Name(), value, ErrNeedsConfirmation)}
+	}
+	return ApplyConfirmed(s, value)
+}
+
+// ApplyConfirmed is like Apply, but also applies the values that need to be
+// confirmed, so it is meant for values the user confirmed explicitly. The
+// min_kernel key of the config file is not checked, as it is not a setting.
+func ApplyConfirmed(s Setting, value string) Result {
+	r := Result{Name: s.Name(), Requested: value}
+	if r.Err = s.Available(); r.Err != nil {
+		return r
+	}
+	if err := Validate(s, value); err != nil {
+		r.Err = fmt.Errorf("invalid value %q for %s: %v", value, s.Name(), err)
+		return r
+	}
+
+	previous, err := s.Status()
+	if err == nil {
+		r.Previous = previous
+	}
+	if r.Err = s.Apply(value); r.Err != nil {
+		return r
+	}
+	r.Changed = err != nil || previous != Normalize(s, value)
+	if current, err := s.Status(); err == nil {
+		r.New = current
+	}
+	return r
+}
+
+// ApplyAll applies the given values, indexed by setting name, to the
+// registered settings, in the order in which they are applied, and returns
+// the outcome for each of them, as returned by Apply, so the values that need
+// to be confirmed are not applied. Values for unknown settings are ignored.
+func ApplyAll(values map[string]string) []Result {
+	var results []Result
+	for _, s := range registry {
+		if value, ok := values[s.Name()]; ok {
+			results = append(results, Apply(s, value))
+		}
+	}
+	return results
+}
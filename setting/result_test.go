@@ -0,0 +1,122 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setting
+
+import (
+	"errors"
+	"testing"
+)
+
+// fakeSetting is a setting whose state is a plain value, with accessors that
+// can be made to fail.
+type fakeSetting struct {
+	value        string
+	unavailable  error
+	applyErr     error
+	statusErr    error
+	confirmation bool
+	force        bool
+}
+
+func (f *fakeSetting) Name() string        { return "fake" }
+func (f *fakeSetting) Description() string { return "fake setting" }
+func (f *fakeSetting) Values() []string    { return []string{Enabled, Disabled} }
+func (f *fakeSetting) Available() error    { return f.unavailable }
+
+func (f *fakeSetting) Apply(value string) error {
+	if f.applyErr != nil {
+		return f.applyErr
+	}
+	f.value = Normalize(f, value)
+	return nil
+}
+
+func (f *fakeSetting) Status() (string, error) {
+	if f.statusErr != nil {
+		return "", f.statusErr
+	}
+	return f.value, nil
+}
+
+func (f *fakeSetting) NeedsConfirmation(value string) bool { return f.confirmation }
+func (f *fakeSetting) NeedsForce(value string) bool        { return f.force }
+
+func TestApplyChanged(t *testing.T) {
+	s := &fakeSetting{value: Enabled}
+	want := Result{Name: "fake", Requested: Disabled, Previous: Enabled, New: Disabled, Changed: true}
+	if got := Apply(s, Disabled); got != want {
+		t.Errorf("Apply() = %+v, want %+v", got, want)
+	}
+}
+
+func TestApplyUnchanged(t *testing.T) {
+	s := &fakeSetting{value: Disabled}
+	want := Result{Name: "fake", Requested: "DISABLED", Previous: Disabled, New: Disabled}
+	if got := Apply(s, "DISABLED"); got != want {
+		t.Errorf("Apply() = %+v, want %+v", got, want)
+	}
+}
+
+func TestApplyStatusUnreadable(t *testing.T) {
+	// Without a status to compare to, the value is assumed to change.
+	s := &fakeSetting{value: Disabled, statusErr: errors.New("unreadable")}
+	want := Result{Name: "fake", Requested: Disabled, Changed: true}
+	if got := Apply(s, Disabled); got != want {
+		t.Errorf("Apply() = %+v, want %+v", got, want)
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	applyErr := errors.New("write failed")
+	unavailable := errors.New("unavailable")
+	tests := []struct {
+		name    string
+		setting *fakeSetting
+		value   string
+		// previous is the status expected before applying.
+		previous string
+		// is is the error expected, or nil if any error will do.
+		is error
+	}{
+		{"apply fails", &fakeSetting{value: Enabled, applyErr: applyErr}, Disabled, Enabled, applyErr},
+		{"unavailable", &fakeSetting{value: Enabled, unavailable: unavailable}, Disabled, "", unavailable},
+		{"invalid value", &fakeSetting{value: Enabled}, "maybe", "", nil},
+		{"needs confirmation", &fakeSetting{value: Enabled, confirmation: true}, Disabled, "", ErrNeedsConfirmation},
+		{"needs force", &fakeSetting{value: Enabled, force: true}, Disabled, "", ErrNeedsConfirmation},
+	}
+	for _, tt := range tests {
+		got := Apply(tt.setting, tt.value)
+		if got.Err == nil {
+			t.Errorf("%s: Apply() succeeded, want an error", tt.name)
+			continue
+		}
+		if tt.is != nil && !errors.Is(got.Err, tt.is) {
+			t.Errorf("%s: Apply() error = %v, want %v", tt.name, got.Err, tt.is)
+		}
+		if got.Changed || got.Previous != tt.previous || got.New != "" {
+			t.Errorf("%s: Apply() = %+v, want unchanged, with previous %q", tt.name, got, tt.previous)
+		}
+		if tt.setting.value != Enabled {
+			t.Errorf("%s: value changed to %q despite the error", tt.name, tt.setting.value)
+		}
+	}
+}
+
+func TestApplyConfirmed(t *testing.T) {
+	s := &fakeSetting{value: Enabled, confirmation: true, force: true}
+	want := Result{Name: "fake", Requested: Disabled, Previous: Enabled, New: Disabled, Changed: true}
+	if got := ApplyConfirmed(s, Disabled); got != want {
+		t.Errorf("ApplyConfirmed() = %+v, want %+v", got, want)
+	}
+}
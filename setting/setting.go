@@ -0,0 +1,231 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setting
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Setting is a processor or kernel feature managed by ryzen-stabilizator, such
+// as C6 C-state or processor boosting. Packages implementing a feature
+// register it with Register, from an init function, so that the command-line
+// tool picks it up for config handling and status.
+type Setting interface {
+	// Name returns the key identifying the setting in the config file.
+	Name() string
+	// Description returns a human-readable name for the setting, e.g.
+	// "C6 C-state".
+	Description() string
+	// Values returns the values accepted in the config file, for display.
+	Values() []string
+	// Available returns nil if the setting can be managed on this machine,
+	// or an error explaining what is missing otherwise.
+	Available() error
+	// Apply changes the setting to the provided value.
+	Apply(value string) error
+	// Status returns the current value of the setting.
+	Status() (string, error)
+}
+
+// Normalizer is implemented by settings that accept more than one spelling
+// for the same value.
+type Normalizer interface {
+	// Normalize returns the canonical form of value, which is the one
+	// reported by Status.
+	Normalize(value string) string
+}
+
+// Confirmable is implemented by settings for which some values may leave the
+// machine unstable if applied by accident, e.g. on the wrong machine.
+type Confirmable interface {
+	// NeedsConfirmation returns whether applying value should be confirmed
+	// by the user first.
+	NeedsConfirmation(value string) bool
+}
+
+// Forcer is implemented by Confirmable settings for which some values may
+// keep the machine from booting, e.g. voltages. Those are only applied when
+// the user confirms them interactively, or with -force; unlike other
+// confirmations, they are not assumed when nobody is asked.
+type Forcer interface {
+	// NeedsForce returns whether applying value requires an explicit
+	// confirmation.
+	NeedsForce(value string) bool
+}
+
+// Persister is implemented by settings that know whether, and how, their
+// value persists across reboots.
+type Persister interface {
+	// Persistence describes whether the value persists across reboots, e.g.
+	// Volatile.
+	Persistence() string
+}
+
+// Defaulter is implemented by settings that know the value they have at boot,
+// before anything changes them, so that it can be restored.
+type Defaulter interface {
+	// Default returns the value the setting has at boot, in the form
+	// returned by Status, or an empty string if it is not known.
+	Default() string
+}
+
+// Explainer is implemented by settings that can tell which mechanism they use,
+// for debugging, e.g. which MSR or sysfs file they read and write.
+type Explainer interface {
+	// Mechanism describes what the setting reads and writes, e.g.
+	// "MSR 0xC0010292 bit 32".
+	Mechanism() string
+}
+
+// Overridable is implemented by settings that kernel command line parameters
+// may override, e.g. nosmt for the online cores, so that a setting that does
+// not stick can be explained.
+type Overridable interface {
+	// KernelParameters returns the names of those parameters, e.g. "idle".
+	KernelParameters() []string
+}
+
+// Volatile is the persistence of settings whose value is lost at reboot, so
+// they must be applied at every boot, e.g. with the systemd service.
+const Volatile = "lost at reboot"
+
+// Validator is implemented by settings whose accepted values cannot be listed,
+// e.g. a list of CPUs.
+type Validator interface {
+	// Validate returns nil if value is accepted by the setting, or an
+	// error explaining why not otherwise.
+	Validate(value string) error
+}
+
+var (
+	// registry has the registered settings, in the order in which they are
+	// applied, as given by ApplyOrder.
+	registry []Setting
+)
+
+// checker is implemented by the generic settings, such as MSRBit, that can
+// tell whether they are fully defined.
+type checker interface {
+	check() error
+}
+
+// Register makes a setting available to ryzen-stabilizator, at its position
+// in the apply order. It panics if a setting with the same name has already
+// been registered, or if the setting is not fully defined, e.g. an MSRBit
+// without SetMeans.
+func Register(s Setting) {
+	if Lookup(s.Name()) != nil {
+		panic(fmt.Sprintf("setting: Register called twice for %q", s.Name()))
+	}
+	if c, ok := s.(checker); ok {
+		if err := c.check(); err != nil {
+			panic(fmt.Sprintf("setting: Register called with %v", err))
+		}
+	}
+	// After every setting with the same position, to keep the order in
+	// which they are registered.
+	i := sort.Search(len(registry), func(i int) bool {
+		return ApplyOrder(registry[i]) > ApplyOrder(s)
+	})
+	registry = append(registry, nil)
+	copy(registry[i+1:], registry[i:])
+	registry[i] = s
+}
+
+// All returns every registered setting, in the order in which they are
+// applied.
+func All() []Setting {
+	return append([]Setting(nil), registry...)
+}
+
+// Lookup returns the setting with the given name, or nil if there is none.
+func Lookup(name string) Setting {
+	for _, s := range registry {
+		if s.Name() == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// Normalize returns the canonical form of value for the given setting, so
+// that it can be compared to the value returned by its Status method.
+func Normalize(s Setting, value string) string {
+	if n, ok := s.(Normalizer); ok {
+		return n.Normalize(value)
+	}
+	return strings.ToLower(value)
+}
+
+// Validate returns nil if value is accepted by the given setting, in any of
+// its spellings, or an error explaining why not otherwise.
+func Validate(s Setting, value string) error {
+	if v, ok := s.(Validator); ok {
+		return v.Validate(value)
+	}
+	normalized := Normalize(s, value)
+	for _, v := range s.Values() {
+		if Normalize(s, v) == normalized {
+			return nil
+		}
+	}
+	return fmt.Errorf("expected one of %s", strings.Join(s.Values(), ", "))
+}
+
+// NeedsForce returns whether applying value to the given setting requires an
+// explicit confirmation, as described in Forcer.
+func NeedsForce(s Setting, value string) bool {
+	f, ok := s.(Forcer)
+	return ok && f.NeedsForce(value)
+}
+
+// Default returns the value the given setting has at boot, as reported by its
+// Default method, and whether it is known at all.
+func Default(s Setting) (string, bool) {
+	if d, ok := s.(Defaulter); ok && d.Default() != "" {
+		return d.Default(), true
+	}
+	return "", false
+}
+
+// Mechanism describes what the given setting reads and writes, as reported by
+// its Mechanism method, or returns "unknown".
+func Mechanism(s Setting) string {
+	if e, ok := s.(Explainer); ok && e.Mechanism() != "" {
+		return e.Mechanism()
+	}
+	return "unknown"
+}
+
+// KernelParameters returns the names of the kernel command line parameters
+// that may override the given setting, as reported by its KernelParameters
+// method, if any.
+func KernelParameters(s Setting) []string {
+	if o, ok := s.(Overridable); ok {
+		return o.KernelParameters()
+	}
+	return nil
+}
+
+// Persistence describes whether the value of the given setting persists
+// across reboots. Unless the setting says otherwise, it is Volatile, as is
+// everything ryzen-stabilizator changes.
+func Persistence(s Setting) string {
+	if p, ok := s.(Persister); ok && p.Persistence() != "" {
+		return p.Persistence()
+	}
+	return Volatile
+}
@@ -0,0 +1,46 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setting
+
+import (
+	"testing"
+)
+
+func TestRegisterOrder(t *testing.T) {
+	saved := registry
+	defer func() { registry = saved }()
+	registry = nil
+
+	// Registered out of order, with two at the same position and one
+	// without any.
+	for _, s := range []*Toggle{
+		{Key: "late", Order: OrderTHP},
+		{Key: "none"},
+		{Key: "first", Order: OrderSMT},
+		{Key: "c6", Order: OrderC6},
+		{Key: "c6package", Order: OrderC6},
+	} {
+		Register(s)
+	}
+	want := []string{"first", "c6", "c6package", "late", "none"}
+	all := All()
+	if len(all) != len(want) {
+		t.Fatalf("All() returned %d settings, expected %d", len(all), len(want))
+	}
+	for i, s := range all {
+		if s.Name() != want[i] {
+			t.Errorf("All()[%d] = %q, expected %q", i, s.Name(), want[i])
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setting
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/sysfs"
+)
+
+// Sysfs is a Setting whose value is the contents of a sysfs file, e.g. the
+// transparent hugepage mode, so that such settings need no code of their own.
+// The file is written with sysfs.Write, so its path must be one of the
+// sysfs.AllowedPrefixes or sysfs.AllowedFiles, and every write is read back
+// to verify it.
+type Sysfs struct {
+	// Key is the name of the setting in the config file.
+	Key string
+	// Label is the human-readable name of the setting.
+	Label string
+	// Path is the sysfs file with the value.
+	Path string
+	// Accepted lists the values accepted in the config file, for display
+	// and, unless Check is set, validation; a nil Accepted means the file
+	// accepts values that cannot be listed.
+	Accepted []string
+	// Check, if set, validates values instead of Accepted, e.g. against
+	// what the running kernel lists in the file.
+	Check func(value string) error
+	// CaseInsensitive indicates values are written in lower case, however
+	// they are spelled in the config file.
+	CaseInsensitive bool
+	// Availability reports whether the setting can be managed; a nil
+	// Availability means it is whenever Path exists.
+	Availability func() error
+	// Persists describes how the setting can persist across reboots; an
+	// empty Persists means it is Volatile.
+	Persists string
+	// BootDefault is the value of the file at boot, if known.
+	BootDefault string
+	// KernelParams lists the kernel command line parameters that may
+	// override the setting.
+	KernelParams []string
+	// Order is the position of the setting in the apply order, e.g.
+	// OrderTHP; the zero value means OrderLast.
+	Order int
+}
+
+// Name returns the key of the setting in the config file.
+func (s *Sysfs) Name() string {
+	return s.Key
+}
+
+// Description returns the human-readable name of the setting.
+func (s *Sysfs) Description() string {
+	return s.Label
+}
+
+// ApplyOrder returns Order, the position of the setting in the apply order.
+func (s *Sysfs) ApplyOrder() int {
+	return s.Order
+}
+
+// Values returns the values accepted in the config file.
+func (s *Sysfs) Values() []string {
+	if s.Accepted == nil {
+		return []string{"any value accepted by " + s.Path}
+	}
+	return s.Accepted
+}
+
+// Available reports whether the setting can be managed on this machine.
+func (s *Sysfs) Available() error {
+	if err := sysfs.CheckPath(s.Path); err != nil {
+		return err
+	}
+	if s.Availability != nil {
+		return s.Availability()
+	}
+	if _, err := os.Stat(s.Path); err != nil {
+		return fmt.Errorf("%s does not exist", s.Path)
+	}
+	return nil
+}
+
+// Normalize returns value without surrounding spaces and, if the setting is
+// CaseInsensitive, in lower case. Otherwise, case is kept, since sysfs values
+// may be case sensitive.
+func (s *Sysfs) Normalize(value string) string {
+	value = strings.TrimSpace(value)
+	if s.CaseInsensitive {
+		return strings.ToLower(value)
+	}
+	return value
+}
+
+// Validate checks Path is allowed, and value is accepted by Check or is one
+// of the Accepted values.
+func (s *Sysfs) Validate(value string) error {
+	if err := sysfs.CheckPath(s.Path); err != nil {
+		return err
+	}
+	value = s.Normalize(value)
+	if s.Check != nil {
+		return s.Check(value)
+	}
+	if s.Accepted == nil {
+		return nil
+	}
+	for _, v := range s.Accepted {
+		if s.Normalize(v) == value {
+			return nil
+		}
+	}
+	return fmt.Errorf("expected one of %s", strings.Join(s.Accepted, ", "))
+}
+
+// Apply writes value to the file, once validated.
+func (s *Sysfs) Apply(value string) error {
+	if err := s.Validate(value); err != nil {
+		return fmt.Errorf("invalid value %q for %s: %v", value, s.Key, err)
+	}
+	return sysfs.Write(s.Path, s.Normalize(value))
+}
+
+// Status returns the current value of the file.
+func (s *Sysfs) Status() (string, error) {
+	return sysfs.Read(s.Path)
+}
+
+// Persistence describes whether the setting persists across reboots.
+func (s *Sysfs) Persistence() string {
+	if s.Persists == "" {
+		return Volatile
+	}
+	return s.Persists
+}
+
+// Default returns the value of the file at boot, if known.
+func (s *Sysfs) Default() string {
+	return s.BootDefault
+}
+
+// KernelParameters returns the kernel command line parameters that may
+// override the setting.
+func (s *Sysfs) KernelParameters() []string {
+	return s.KernelParams
+}
+
+// Mechanism returns the sysfs file with the value.
+func (s *Sysfs) Mechanism() string {
+	return "sysfs " + s.Path
+}
@@ -0,0 +1,160 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setting
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// Enabled is the status of a toggle that is enabled.
+	Enabled = "enabled"
+	// Disabled is the status of a toggle that is disabled.
+	Disabled = "disabled"
+)
+
+// Toggle is a Setting that can be either enabled or disabled. In the config
+// file, it accepts as values `enable' and `disable' (or `enabled' and
+// `disabled').
+type Toggle struct {
+	// Key is the name of the setting in the config file.
+	Key string
+	// Label is the human-readable name of the setting.
+	Label string
+	// Availability reports whether the setting can be managed; a nil
+	// Availability means it is always available.
+	Availability func() error
+	// Enable and Disable change the setting.
+	Enable  func() error
+	Disable func() error
+	// IsEnabled returns whether the setting is currently enabled.
+	IsEnabled func() (bool, error)
+	// ConfirmValues lists the values, either Enabled or Disabled, that may
+	// leave the machine unstable and should be confirmed before applied.
+	ConfirmValues []string
+	// Persists describes how the setting can persist across reboots; an
+	// empty Persists means it is Volatile.
+	Persists string
+	// Touches describes the mechanism used to manage the setting, e.g. the
+	// MSR or the sysfs file changed.
+	Touches string
+	// BootDefault is the value, either Enabled or Disabled, the setting has
+	// at boot, as set by the kernel or firmware; empty if it is not known.
+	BootDefault string
+	// KernelParams lists the kernel command line parameters that may
+	// override the setting, e.g. "idle".
+	KernelParams []string
+	// Order is the position of the setting in the apply order, e.g.
+	// OrderC6; the zero value means OrderLast.
+	Order int
+}
+
+// Name returns the key of the toggle in the config file.
+func (t *Toggle) Name() string {
+	return t.Key
+}
+
+// Description returns the human-readable name of the toggle.
+func (t *Toggle) Description() string {
+	return t.Label
+}
+
+// Values returns the values accepted by the toggle in the config file.
+func (t *Toggle) Values() []string {
+	return []string{"enable", "disable"}
+}
+
+// Persistence describes whether the toggle persists across reboots.
+func (t *Toggle) Persistence() string {
+	if t.Persists == "" {
+		return Volatile
+	}
+	return t.Persists
+}
+
+// Default returns the value of the toggle at boot, if known.
+func (t *Toggle) Default() string {
+	return t.BootDefault
+}
+
+// Mechanism describes what the toggle reads and writes.
+func (t *Toggle) Mechanism() string {
+	return t.Touches
+}
+
+// KernelParameters returns the kernel command line parameters that may
+// override the toggle.
+func (t *Toggle) KernelParameters() []string {
+	return t.KernelParams
+}
+
+// ApplyOrder returns Order, the position of the toggle in the apply order.
+func (t *Toggle) ApplyOrder() int {
+	return t.Order
+}
+
+// Available reports whether the toggle can be managed on this machine.
+func (t *Toggle) Available() error {
+	if t.Availability == nil {
+		return nil
+	}
+	return t.Availability()
+}
+
+// Normalize maps the accepted spellings of a value to either Enabled or
+// Disabled.
+func (t *Toggle) Normalize(value string) string {
+	switch strings.ToLower(value) {
+	case "enable", Enabled:
+		return Enabled
+	case "disable", Disabled:
+		return Disabled
+	}
+	return strings.ToLower(value)
+}
+
+// Apply enables or disables the toggle.
+func (t *Toggle) Apply(value string) error {
+	switch t.Normalize(value) {
+	case Enabled:
+		return t.Enable()
+	case Disabled:
+		return t.Disable()
+	}
+	return fmt.Errorf("invalid value %q for %s; expected either \"enable\" or \"disable\"", value, t.Key)
+}
+
+// Status returns either Enabled or Disabled.
+func (t *Toggle) Status() (string, error) {
+	enabled, err := t.IsEnabled()
+	if err != nil {
+		return "", err
+	}
+	if enabled {
+		return Enabled, nil
+	}
+	return Disabled, nil
+}
+
+// NeedsConfirmation returns whether value is one of the ConfirmValues.
+func (t *Toggle) NeedsConfirmation(value string) bool {
+	normalized := t.Normalize(value)
+	for _, v := range t.ConfirmValues {
+		if v == normalized {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smt
+
+import (
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/setting"
+)
+
+func init() {
+	// Disabling SMT brings half of the CPUs offline, so it is confirmed.
+	setting.Register(&setting.Toggle{
+		Key:           "smt",
+		Label:         "simultaneous multithreading (SMT)",
+		Availability:  Available,
+		Enable:        Enable,
+		Disable:       Disable,
+		IsEnabled:     Enabled,
+		ConfirmValues: []string{setting.Disabled},
+		Persists:      "lost at reboot, unless disabled with the nosmt kernel parameter",
+		BootDefault:   setting.Enabled,
+		Touches:       smtControlFile,
+		KernelParams:  []string{"nosmt"},
+		Order:         setting.OrderSMT,
+	})
+}
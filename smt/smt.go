@@ -0,0 +1,105 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smt
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+const (
+	smtControlFile = "/sys/devices/system/cpu/smt/control"
+)
+
+var (
+	// ErrUnsupported indicates the processor, or the kernel, does not
+	// support controlling simultaneous multithreading (SMT).
+	ErrUnsupported = errors.New("SMT control not supported by the processor or the kernel")
+	// ErrForcedOff indicates SMT was disabled with the nosmt=force kernel
+	// parameter, and cannot be enabled until reboot.
+	ErrForcedOff = errors.New("SMT forced off with nosmt=force; it cannot be enabled until reboot")
+)
+
+// control returns the contents of the SMT control file, e.g. "on".
+func control() (string, error) {
+	value, err := ioutil.ReadFile(smtControlFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(value)), nil
+}
+
+// Available returns nil if SMT can be enabled and disabled, or an error
+// explaining why not otherwise.
+func Available() error {
+	if _, err := os.Stat(smtControlFile); err != nil {
+		return ErrUnsupported
+	}
+	value, err := control()
+	if err != nil {
+		return err
+	}
+	switch value {
+	case "on", "off":
+		return nil
+	case "forceoff":
+		return ErrForcedOff
+	default:
+		// notsupported and notimplemented.
+		return ErrUnsupported
+	}
+}
+
+// Enabled returns a boolean indicating whether SMT is enabled or not.
+func Enabled() (bool, error) {
+	value, err := control()
+	if err != nil {
+		return false, err
+	}
+	return value == "on", nil
+}
+
+// changeSMT either enables or disables SMT, depending on whether the provided
+// parameter is true or false, respectively. The kernel brings the sibling
+// threads online or offline accordingly.
+func changeSMT(enable bool) error {
+	value := "off"
+	if enable {
+		value = "on"
+	}
+	if err := ioutil.WriteFile(smtControlFile, []byte(value), 0644); err != nil {
+		return err
+	}
+	enabled, err := Enabled()
+	if err != nil {
+		return err
+	}
+	if enabled != enable {
+		return fmt.Errorf("SMT did not change as requested")
+	}
+	return nil
+}
+
+// Enable enables SMT, bringing the sibling threads online.
+func Enable() error {
+	return changeSMT(true)
+}
+
+// Disable disables SMT, bringing the sibling threads offline.
+func Disable() error {
+	return changeSMT(false)
+}
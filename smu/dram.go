@@ -0,0 +1,103 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smu
+
+// The UMC (unified memory controller) registers below, and their layout, were
+// obtained from the ZenTimings project available at
+// https://github.com/irusanov/ZenTimings. They are only known to hold for
+// desktop and mobile Zen 2 and Zen 3 processors.
+const (
+	// umcCount is how many UMCs (one per memory channel) we probe for.
+	umcCount = 2
+	// umcStride separates the registers of each UMC in the SMN.
+	umcStride = 0x00100000
+
+	// umcConfig has the memory clock ratio, gear down mode and command rate.
+	umcConfig = 0x00050200
+	// umcTiming1 has tCL, tRAS, tRCDRD and tRCDWR.
+	umcTiming1 = 0x00050204
+	// umcTiming2 has tRC and tRP.
+	umcTiming2 = 0x00050208
+)
+
+// dramCodenames are the processors whose UMC registers are known.
+var dramCodenames = map[Codename]bool{
+	Matisse:  true,
+	Renoir:   true,
+	Lucienne: true,
+	Vermeer:  true,
+	Cezanne:  true,
+}
+
+// DRAMInfo is the basic configuration of the memory, as programmed in the
+// memory controller.
+type DRAMInfo struct {
+	// Speed is the transfer rate, in MT/s, e.g. 3600 for DDR4-3600.
+	Speed int
+	// GearDown indicates whether gear down mode (GDM) is enabled.
+	GearDown bool
+	// CommandRate is either 1 or 2, for 1T or 2T.
+	CommandRate int
+	// The primary timings, in memory clock cycles.
+	CL, RCDRD, RCDWR, RP, RAS, RC int
+}
+
+// bits returns the given number of bits of value, starting at offset.
+func bits(value uint32, offset, count uint) int {
+	return int((value >> offset) & (1<<count - 1))
+}
+
+// DRAM returns the memory configuration, read from the first populated
+// memory channel. It returns ErrUnsupported if the registers of the memory
+// controller of this processor are not known. The registers of a channel are
+// read in a row, holding mu, so that reads of other SMN registers, e.g. the
+// temperatures read concurrently in the status, cannot come in between and
+// make them describe different things.
+func DRAM() (DRAMInfo, error) {
+	codename, err := ProcessorCodename()
+	if err != nil {
+		return DRAMInfo{}, err
+	}
+	if !dramCodenames[codename] {
+		return DRAMInfo{}, ErrUnsupported
+	}
+
+	for umc := uint32(0); umc < umcCount; umc++ {
+		base := umc * umcStride
+		values, err := readRegisters(base+umcConfig, base+umcTiming1, base+umcTiming2)
+		if err != nil {
+			return DRAMInfo{}, err
+		}
+		config, timing1, timing2 := values[0], values[1], values[2]
+		// An unpopulated channel reads as zero.
+		if config == 0 {
+			continue
+		}
+
+		info := DRAMInfo{
+			// The ratio is in thirds of 100 MHz, at double data rate.
+			Speed:       bits(config, 0, 7) * 200 / 3,
+			GearDown:    bits(config, 11, 1) != 0,
+			CommandRate: 1 + bits(config, 10, 1),
+			CL:          bits(timing1, 0, 6),
+			RAS:         bits(timing1, 8, 7),
+			RCDRD:       bits(timing1, 16, 6),
+			RCDWR:       bits(timing1, 24, 6),
+			RC:          bits(timing2, 0, 8),
+			RP:          bits(timing2, 16, 6),
+		}
+		return info, nil
+	}
+	return DRAMInfo{}, ErrUnsupported
+}
@@ -0,0 +1,83 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smu
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrUnavailable is returned, as a RetryError, when reading from the
+	// ryzen_smu module still fails after RetryBudget retries, e.g. because
+	// the SMU keeps being busy.
+	ErrUnavailable = errors.New("SMU data unavailable")
+
+	// errShortRead is returned when the ryzen_smu module returns less data
+	// than expected, which happens transiently, e.g. while the SMU updates
+	// the PM table.
+	errShortRead = errors.New("short read")
+)
+
+// RetryError is returned when reading from the ryzen_smu module still fails
+// after RetryBudget retries. It wraps the last error, and is ErrUnavailable
+// as well, so that errors.Is can detect either.
+type RetryError struct {
+	// Retries is how many times the read was retried.
+	Retries int
+	// Err is the error of the last attempt.
+	Err error
+}
+
+// Error describes the last error along with the number of retries.
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("%v: still failing after %d retries: %v", ErrUnavailable, e.Retries, e.Err)
+}
+
+// Unwrap returns the error of the last attempt, e.g. syscall.EBUSY.
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// Is returns whether target is ErrUnavailable.
+func (e *RetryError) Is(target error) bool {
+	return target == ErrUnavailable
+}
+
+// transient returns whether a failed read from the ryzen_smu module may
+// succeed if retried.
+func transient(err error) bool {
+	return errors.Is(err, errShortRead) || errors.Is(err, syscall.EBUSY) || errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EINTR)
+}
+
+// retryRead calls read until it succeeds or fails with an error that is not
+// transient, retrying up to RetryBudget times, with exponential backoff, like
+// commands rejected because the SMU is busy. If every attempt failed, the
+// last error is returned, wrapped in a RetryError.
+func retryRead(read func() error) error {
+	backoff := initialBackoff
+	for retry := 0; ; retry++ {
+		err := read()
+		if err == nil || !transient(err) {
+			return err
+		}
+		if retry >= RetryBudget {
+			return &RetryError{Retries: RetryBudget, Err: err}
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smu
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+)
+
+func TestRetryReadExhausted(t *testing.T) {
+	saved := initialBackoff
+	initialBackoff = 0
+	defer func() { initialBackoff = saved }()
+
+	attempts := 0
+	err := retryRead(func() error {
+		attempts++
+		return syscall.EBUSY
+	})
+	if attempts != RetryBudget+1 {
+		t.Errorf("read attempted %d times, want %d", attempts, RetryBudget+1)
+	}
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("retryRead() = %v, want it to be %v", err, ErrUnavailable)
+	}
+	if !errors.Is(err, syscall.EBUSY) {
+		t.Errorf("retryRead() = %v, want it to wrap %v", err, syscall.EBUSY)
+	}
+}
+
+func TestRetryReadRecovers(t *testing.T) {
+	saved := initialBackoff
+	initialBackoff = 0
+	defer func() { initialBackoff = saved }()
+
+	attempts := 0
+	err := retryRead(func() error {
+		attempts++
+		if attempts < 3 {
+			return errShortRead
+		}
+		return nil
+	})
+	if err != nil || attempts != 3 {
+		t.Errorf("retryRead() = %v after %d attempts, want nil after 3", err, attempts)
+	}
+}
+
+func TestRetryReadPermanent(t *testing.T) {
+	attempts := 0
+	err := retryRead(func() error {
+		attempts++
+		return syscall.ENOENT
+	})
+	if attempts != 1 || err != syscall.ENOENT {
+		t.Errorf("retryRead() = %v after %d attempts, want %v after 1", err, attempts, syscall.ENOENT)
+	}
+	if errors.Is(err, ErrUnavailable) {
+		t.Errorf("retryRead() = %v, want it not to be %v", err, ErrUnavailable)
+	}
+}
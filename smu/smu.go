@@ -0,0 +1,275 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smu
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The SMU (System Management Unit) is accessed through the interface exposed
+// by the ryzen_smu kernel module, available at
+// https://gitlab.com/leogx9r/ryzen_smu.
+const (
+	driverDir    = "/sys/kernel/ryzen_smu_drv"
+	codenameFile = driverDir + "/codename"
+	argsFile     = driverDir + "/smu_args"
+	rsmuCmdFile  = driverDir + "/rsmu_cmd"
+
+	// The mailbox takes and returns six 32-bit arguments.
+	argCount = 6
+)
+
+// Responses reported by the SMU after executing a command.
+const (
+	statusOK              = 0x01
+	statusFailed          = 0xFF
+	statusUnknownCmd      = 0xFE
+	statusRejectedPrereq  = 0xFD
+	statusRejectedBusy    = 0xFC
+	statusCommandTimedOut = 0xFB
+)
+
+// Codename identifies the processor, as reported by the ryzen_smu module.
+type Codename int
+
+// Codenames as numbered by the ryzen_smu module.
+const (
+	Undefined Codename = iota
+	Colfax
+	Renoir
+	Picasso
+	Matisse
+	Threadripper
+	CastlePeak
+	RavenRidge
+	RavenRidge2
+	SummitRidge
+	PinnacleRidge
+	Rembrandt
+	Vermeer
+	VanGogh
+	Cezanne
+	Milan
+	Dali
+	Lucienne
+	Naples
+	Chagall
+)
+
+var (
+	codenames = map[Codename]string{
+		Colfax:        "Colfax",
+		Renoir:        "Renoir",
+		Picasso:       "Picasso",
+		Matisse:       "Matisse",
+		Threadripper:  "Threadripper",
+		CastlePeak:    "Castle Peak",
+		RavenRidge:    "Raven Ridge",
+		RavenRidge2:   "Raven Ridge 2",
+		SummitRidge:   "Summit Ridge",
+		PinnacleRidge: "Pinnacle Ridge",
+		Rembrandt:     "Rembrandt",
+		Vermeer:       "Vermeer",
+		VanGogh:       "Van Gogh",
+		Cezanne:       "Cezanne",
+		Milan:         "Milan",
+		Dali:          "Dali",
+		Lucienne:      "Lucienne",
+		Naples:        "Naples",
+		Chagall:       "Chagall",
+	}
+
+	// getMaxFrequency has the RSMU command that returns the current boost
+	// frequency ceiling, in MHz, for each supported codename. Command IDs
+	// obtained from the ZenStates-Core project available at
+	// https://github.com/irusanov/ZenStates-Core.
+	getMaxFrequency = map[Codename]uint32{
+		Matisse: 0x6E,
+		Vermeer: 0x6E,
+	}
+
+	// ErrUnsupported is returned when the requested operation is not known
+	// for the running processor.
+	ErrUnsupported = errors.New("operation not supported by the SMU of this processor")
+
+	// ErrBusy is returned when the SMU rejects a command because it is busy
+	// serving another agent, e.g. the kernel.
+	ErrBusy = errors.New("SMU command rejected: SMU busy")
+
+	// ErrTimeout is returned when the SMU remains busy after every retry.
+	ErrTimeout = errors.New("timed out waiting for the SMU")
+
+	// RetryBudget is the number of times a command rejected because the SMU
+	// is busy is retried, with exponential backoff, before giving up with
+	// ErrTimeout.
+	RetryBudget = 5
+
+	// initialBackoff is how long we wait before the first retry.
+	initialBackoff = 10 * time.Millisecond
+
+	// mu serializes access to the files of the ryzen_smu module. Reading an
+	// SMN register takes writing its address and then reading the value
+	// back, and a command takes writing its arguments, then the command,
+	// and reading both back, in files shared by every caller; interleaved,
+	// they would return the values of another register or command, without
+	// any error. Status is read concurrently, so every access holds it.
+	mu sync.Mutex
+)
+
+// String returns the name of the codename.
+func (c Codename) String() string {
+	if name, ok := codenames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown (%d)", int(c))
+}
+
+// statusError converts a response from the SMU into an error.
+func statusError(status uint32) error {
+	switch status {
+	case statusOK:
+		return nil
+	case statusFailed:
+		return fmt.Errorf("SMU command failed")
+	case statusUnknownCmd:
+		return fmt.Errorf("SMU command unknown")
+	case statusRejectedPrereq:
+		return fmt.Errorf("SMU command rejected: prerequisite not met")
+	case statusRejectedBusy:
+		return ErrBusy
+	case statusCommandTimedOut:
+		return fmt.Errorf("SMU command timed out")
+	}
+	return fmt.Errorf("unexpected SMU response 0x%X", status)
+}
+
+// Available returns a boolean indicating whether we have SMU access available
+// or not. We require the `ryzen_smu' module for it to be available.
+func Available() bool {
+	if _, err := os.Stat(driverDir); err == nil {
+		return true
+	}
+	return false
+}
+
+// ProcessorCodename returns the codename of the processor, as detected by the
+// ryzen_smu module.
+func ProcessorCodename() (Codename, error) {
+	value, err := ioutil.ReadFile(codenameFile)
+	if err != nil {
+		return Undefined, err
+	}
+	c, err := strconv.Atoi(strings.TrimSpace(string(value)))
+	if err != nil {
+		return Undefined, err
+	}
+	return Codename(c), nil
+}
+
+// command sends a command to the RSMU mailbox with the given arguments and
+// returns the arguments as updated by the SMU, which is how it sends results
+// back. Commands rejected because the SMU is busy are retried up to
+// RetryBudget times.
+func command(op uint32, args ...uint32) ([argCount]uint32, error) {
+	backoff := initialBackoff
+	for retry := 0; ; retry++ {
+		result, err := sendCommand(op, args...)
+		if !errors.Is(err, ErrBusy) {
+			return result, err
+		}
+		if retry >= RetryBudget {
+			return result, fmt.Errorf("%w: command 0x%X still rejected as busy after %d retries", ErrTimeout, op, RetryBudget)
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// sendCommand makes a single attempt at executing a command in the RSMU
+// mailbox.
+func sendCommand(op uint32, args ...uint32) ([argCount]uint32, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var result [argCount]uint32
+	if len(args) > argCount {
+		return result, fmt.Errorf("too many SMU arguments: %d (max %d)", len(args), argCount)
+	}
+
+	data := make([]byte, 4*argCount)
+	for i, arg := range args {
+		binary.LittleEndian.PutUint32(data[4*i:], arg)
+	}
+	if err := ioutil.WriteFile(argsFile, data, 0644); err != nil {
+		return result, err
+	}
+
+	cmd := make([]byte, 4)
+	binary.LittleEndian.PutUint32(cmd, op)
+	if err := ioutil.WriteFile(rsmuCmdFile, cmd, 0644); err != nil {
+		return result, err
+	}
+
+	// Reading the command file back gives us the response from the SMU.
+	status, err := ioutil.ReadFile(rsmuCmdFile)
+	if err != nil {
+		return result, err
+	}
+	if len(status) < 4 {
+		return result, fmt.Errorf("short SMU response: %d bytes", len(status))
+	}
+	if err = statusError(binary.LittleEndian.Uint32(status)); err != nil {
+		return result, err
+	}
+
+	data, err = ioutil.ReadFile(argsFile)
+	if err != nil {
+		return result, err
+	}
+	if len(data) < 4*argCount {
+		return result, fmt.Errorf("short SMU arguments: %d bytes", len(data))
+	}
+	for i := range result {
+		result[i] = binary.LittleEndian.Uint32(data[4*i:])
+	}
+	return result, nil
+}
+
+// BoostLimit returns the current boost frequency ceiling, in MHz, as reported
+// by the SMU. This reflects changes made through Precision Boost Overdrive
+// (PBO), such as its boost override.
+func BoostLimit() (int, error) {
+	codename, err := ProcessorCodename()
+	if err != nil {
+		return 0, err
+	}
+	op, ok := getMaxFrequency[codename]
+	if !ok {
+		return 0, ErrUnsupported
+	}
+
+	result, err := command(op)
+	if err != nil {
+		return 0, err
+	}
+	return int(result[0]), nil
+}
@@ -0,0 +1,195 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smu
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+)
+
+const (
+	smnFile            = driverDir + "/smn"
+	pmTableFile        = driverDir + "/pm_table"
+	pmTableVersionFile = driverDir + "/pm_table_version"
+
+	// thmTconCurTmp is the SMN address of the register with the current
+	// control temperature (Tctl), as used by the k10temp kernel driver.
+	thmTconCurTmp = 0x00059800
+	// curTempRangeSel indicates the temperature is reported in the
+	// -49..206 °C range, instead of 0..255 °C.
+	curTempRangeSel = 1 << 19
+
+	// ccdTemp is the SMN address of the register with the temperature of the
+	// first CCD (core complex die) on Zen 2 and Zen 3, as used by k10temp;
+	// the others follow it.
+	ccdTemp = 0x00059954
+	// ccdTempValid indicates the CCD is present and its temperature valid.
+	ccdTempValid = 1 << 11
+	// maxCCDs is how many CCDs we probe for.
+	maxCCDs = 8
+)
+
+// ReadSMN reads a register from the System Management Network (SMN), at the
+// given address. Transient failures are retried, as described in retryRead.
+func ReadSMN(address uint32) (uint32, error) {
+	values, err := readRegisters(address)
+	if err != nil {
+		return 0, err
+	}
+	return values[0], nil
+}
+
+// readRegisters reads the SMN registers at the given addresses, retrying
+// transient failures, as described in retryRead. The registers are read in a
+// row, with no other access to the SMU in between, so registers describing
+// the same thing are consistent with each other.
+func readRegisters(addresses ...uint32) ([]uint32, error) {
+	var values []uint32
+	err := retryRead(func() error {
+		var err error
+		values, err = readSMN(addresses...)
+		return err
+	})
+	return values, err
+}
+
+// readSMN makes a single attempt at reading registers from the SMN, holding
+// mu across all of them.
+func readSMN(addresses ...uint32) ([]uint32, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	values := make([]uint32, len(addresses))
+	data := make([]byte, 4)
+	for i, address := range addresses {
+		binary.LittleEndian.PutUint32(data, address)
+		if err := ioutil.WriteFile(smnFile, data, 0644); err != nil {
+			return nil, err
+		}
+		value, err := ioutil.ReadFile(smnFile)
+		if err != nil {
+			return nil, err
+		}
+		if len(value) < 4 {
+			return nil, fmt.Errorf("%w of SMN: %d bytes", errShortRead, len(value))
+		}
+		values[i] = binary.LittleEndian.Uint32(value)
+	}
+	return values, nil
+}
+
+// PMTableVersion returns the version of the PM table, which determines its
+// layout. Transient failures are retried, as described in retryRead.
+func PMTableVersion() (uint32, error) {
+	var version uint32
+	err := retryRead(func() error {
+		mu.Lock()
+		value, err := ioutil.ReadFile(pmTableVersionFile)
+		mu.Unlock()
+		if err != nil {
+			return err
+		}
+		if len(value) < 4 {
+			return fmt.Errorf("%w of PM table version: %d bytes", errShortRead, len(value))
+		}
+		version = binary.LittleEndian.Uint32(value)
+		return nil
+	})
+	return version, err
+}
+
+// PMTable returns the SMU power management (PM) table, which is an array of
+// 32-bit floats whose layout depends on the table version. Transient
+// failures, including an empty table, are retried, as described in
+// retryRead.
+func PMTable() ([]float32, error) {
+	var table []float32
+	err := retryRead(func() error {
+		mu.Lock()
+		data, err := ioutil.ReadFile(pmTableFile)
+		mu.Unlock()
+		if err != nil {
+			return err
+		}
+		if len(data) < 4 {
+			return fmt.Errorf("%w of PM table: %d bytes", errShortRead, len(data))
+		}
+		table = make([]float32, len(data)/4)
+		for i := range table {
+			table[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+		}
+		return nil
+	})
+	return table, err
+}
+
+// Temperature returns the current control temperature (Tctl), in °C.
+func Temperature() (float64, error) {
+	value, err := ReadSMN(thmTconCurTmp)
+	if err != nil {
+		return 0, err
+	}
+	// The temperature is in bits 31:21, in steps of 0.125 °C.
+	temp := float64(value>>21) * 0.125
+	if value&curTempRangeSel != 0 {
+		temp -= 49
+	}
+	return temp, nil
+}
+
+// PresentCCDs returns the indices of the CCDs (core complex dies) present in
+// chiplet processors, as reported by their temperature sensors. Monolithic
+// processors have none.
+func PresentCCDs() ([]int, error) {
+	var ccds []int
+	for i := 0; i < maxCCDs; i++ {
+		value, err := ReadSMN(ccdTemp + uint32(i)*4)
+		if err != nil {
+			return nil, err
+		}
+		if value&ccdTempValid != 0 {
+			ccds = append(ccds, i)
+		}
+	}
+	return ccds, nil
+}
+
+// CCDTemperature is the temperature of a CCD.
+type CCDTemperature struct {
+	// CCD is the physical index of the CCD, as returned by PresentCCDs,
+	// which skips the CCDs fused off.
+	CCD int
+	// Celsius is the temperature, in °C.
+	Celsius float64
+}
+
+// CCDTemperatures returns the temperature of each CCD present, which is
+// distinct from the control temperature (Tctl).
+func CCDTemperatures() ([]CCDTemperature, error) {
+	ccds, err := PresentCCDs()
+	if err != nil {
+		return nil, err
+	}
+	temps := make([]CCDTemperature, 0, len(ccds))
+	for _, i := range ccds {
+		value, err := ReadSMN(ccdTemp + uint32(i)*4)
+		if err != nil {
+			return nil, err
+		}
+		temps = append(temps, CCDTemperature{CCD: i, Celsius: float64(value&(ccdTempValid-1))*0.125 - 49})
+	}
+	return temps, nil
+}
@@ -0,0 +1,633 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/amdpstate"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/boosting"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/c6"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/cpuinfo"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/kernel"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/lockdown"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/mce"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/pmtable"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/powerd"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/rapl"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/setting"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/smu"
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/vulnerabilities"
+)
+
+var (
+	// statusTimeout is how long the status reads may take. Reads still
+	// running by then are reported as timed out, so that a single slow
+	// source, e.g. the SMU, does not hang the whole status.
+	statusTimeout = 5 * time.Second
+
+	// idleSampleInterval is how long the cpuidle statistics are sampled for
+	// in verbose status.
+	idleSampleInterval = time.Second
+
+	// powerSampleInterval is how long the RAPL energy counters are sampled
+	// for, to obtain the package power.
+	powerSampleInterval = 500 * time.Millisecond
+
+	// statusWorkers is how many reads, across every part of the status, may
+	// run at once, so that slow sources such as the SMU are not hammered.
+	statusWorkers = 4
+
+	statusSlotsOnce sync.Once
+	statusSlots     chan struct{}
+
+	// inFlight has the sources whose read is still running.
+	inFlight      = map[string]bool{}
+	inFlightMutex sync.Mutex
+
+	// errTimedOut is reported for reads that took longer than statusTimeout.
+	errTimedOut = errors.New("timed out")
+
+	// errStillRunning is reported for reads not performed because an earlier
+	// read of the same source, which timed out, is still running.
+	errStillRunning = errors.New("timed out, earlier read still running")
+)
+
+// settingStatus is the current status of a setting, as included in the JSON
+// output.
+type settingStatus struct {
+	Setting    string `json:"setting"`
+	Status     string `json:"status,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Configured string `json:"configured,omitempty"`
+	Mismatch   bool   `json:"mismatch,omitempty"`
+	// Mechanism is included in verbose mode only.
+	Mechanism string `json:"mechanism,omitempty"`
+}
+
+// statusRead is an independent read of part of the status, producing the
+// lines to be displayed.
+type statusRead struct {
+	// what is read, e.g. "status of C6 C-state", for reporting a timeout.
+	what string
+	read func() []string
+}
+
+// acquireStatusSlot waits until fewer than statusWorkers reads are running,
+// and returns the function releasing the slot taken.
+func acquireStatusSlot() func() {
+	statusSlotsOnce.Do(func() {
+		workers := statusWorkers
+		if workers < 1 {
+			workers = 1
+		}
+		statusSlots = make(chan struct{}, workers)
+	})
+	statusSlots <- struct{}{}
+	return func() { <-statusSlots }
+}
+
+// startRead marks the read of the given source as running, returning false
+// if an earlier read of it, e.g. one that timed out in a previous -watch
+// update, is still running.
+func startRead(source string) bool {
+	inFlightMutex.Lock()
+	defer inFlightMutex.Unlock()
+	if inFlight[source] {
+		return false
+	}
+	inFlight[source] = true
+	return true
+}
+
+// finishRead marks the read of the given source as no longer running.
+func finishRead(source string) {
+	inFlightMutex.Lock()
+	defer inFlightMutex.Unlock()
+	delete(inFlight, source)
+}
+
+// runConcurrently runs the given functions concurrently, at most
+// statusWorkers at once along with any other status reads, and waits for
+// them, up to timeout. sources names what each function reads: a function
+// is not run while an earlier read of the same source is still running, so
+// that a source that hangs holds at most one slot, rather than one more at
+// every update. It returns, for each function, nil if it finished in time,
+// errStillRunning if it was not run, or errTimedOut. Each function must only
+// write its own results, so that those not finished in time can still
+// complete safely.
+func runConcurrently(sources []string, fns []func(), timeout time.Duration) []error {
+	errs := make([]error, len(fns))
+	finished := make(chan int, len(fns))
+	pending := 0
+	for i, fn := range fns {
+		if !startRead(sources[i]) {
+			errs[i] = errStillRunning
+			continue
+		}
+		errs[i] = errTimedOut
+		pending++
+		go func(i int, fn func()) {
+			defer finishRead(sources[i])
+			release := acquireStatusSlot()
+			defer release()
+			fn()
+			finished <- i
+		}(i, fn)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for ; pending > 0; pending-- {
+		select {
+		case i := <-finished:
+			errs[i] = nil
+		case <-timer.C:
+			return errs
+		}
+	}
+	return errs
+}
+
+// collectStatus performs the given reads concurrently, returning the lines
+// produced by each of them, in order.
+func collectStatus(reads []statusRead) []string {
+	results := make([][]string, len(reads))
+	sources := make([]string, len(reads))
+	fns := make([]func(), len(reads))
+	for i := range reads {
+		i := i
+		sources[i] = reads[i].what
+		fns[i] = func() { results[i] = reads[i].read() }
+	}
+
+	var lines []string
+	for i, err := range runConcurrently(sources, fns, statusTimeout) {
+		if err != nil {
+			lines = append(lines, fmt.Sprintf("Error while obtaining %s: %v", reads[i].what, err))
+			continue
+		}
+		lines = append(lines, results[i]...)
+	}
+	return lines
+}
+
+// statusEntries returns the current status of every registered setting that
+// is available and, if cfg is not nil, the configured one.
+func statusEntries(cfg *configuration) []settingStatus {
+	var entries []settingStatus
+	for _, c := range compareSettings(cfg) {
+		if c.unavailable != nil {
+			continue
+		}
+		entry := settingStatus{Setting: c.setting.Name(), Status: c.current, Mismatch: c.mismatch()}
+		if c.err != nil {
+			entry.Error = c.err.Error()
+		}
+		if c.configured {
+			entry.Configured = c.desired
+		}
+		if verbose {
+			entry.Mechanism = setting.Mechanism(c.setting)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// lockdownStatus warns if kernel lockdown will deny MSR writes.
+func lockdownStatus() []string {
+	if !c6.Available() {
+		return nil
+	}
+	// Lockdown only blocks MSR writes; the other reads still work.
+	if err := lockdown.CheckMSRWrites(); err != nil {
+		return []string{fmt.Sprintf("Warning: %v.", err)}
+	}
+	return nil
+}
+
+// microcodeStatus warns if the cores are not all running the same microcode
+// revision, since that can make MSR changes behave differently across cores.
+func microcodeStatus() []string {
+	if !cpuinfo.MicrocodeAvailable() {
+		return nil
+	}
+	revisions, cpus, err := cpuinfo.MicrocodeRevisions()
+	if err != nil {
+		return []string{fmt.Sprintf("Error while obtaining microcode revisions: %v", err)}
+	}
+	if len(revisions) <= 1 {
+		return nil
+	}
+	lines := []string{"Warning: the cores are running different microcode revisions, so changes may not behave consistently across them:"}
+	for _, r := range revisions {
+		lines = append(lines, fmt.Sprintf("  0x%x on CPUs %s", r, intsToString(cpus[r])))
+	}
+	return lines
+}
+
+// idleStatus reports, as a runtime cross-check of the C6 status, whether the
+// cpuidle state C6 is entered through was used during a short interval.
+func idleStatus() []string {
+	state, entries, err := c6.IdleEntries(idleSampleInterval)
+	switch {
+	case errors.Is(err, c6.ErrNoCPUIdle):
+		return nil
+	case err != nil:
+		return []string{fmt.Sprintf("Error while obtaining cpuidle statistics: %v", err)}
+	}
+	evidence := "C6 appears to be in use"
+	if entries == 0 {
+		evidence = "no evidence of C6 in use"
+	}
+	return []string{fmt.Sprintf("Idle state %s (deepest) entered %d times in the last %v; %s.", state.Name, entries, idleSampleInterval, evidence)}
+}
+
+// latencyStatus warns about PM QoS latency constraints that keep C6 from being
+// entered, whatever its status says.
+func latencyStatus() []string {
+	constraints, err := c6.LatencyConstraints()
+	if err != nil {
+		return []string{fmt.Sprintf("Error while obtaining PM QoS latency constraints: %v", err)}
+	}
+	// Without the C6 exit latency, we cannot tell whether a constraint
+	// blocks it, so we report them all.
+	exitLatency, err := c6.IdleExitLatency()
+	if err != nil {
+		exitLatency = -1
+	}
+
+	var lines []string
+	for _, c := range constraints {
+		if exitLatency >= 0 && c.Latency != c6.NoIdle && c.Latency >= exitLatency {
+			continue
+		}
+		cpus := "every CPU"
+		if c.CPUs != nil {
+			cpus = "CPUs " + intsToString(c.CPUs)
+		}
+		limit := "no idle state may be entered"
+		if c.Latency != c6.NoIdle {
+			limit = fmt.Sprintf("the idle exit latency is limited to %d µs", c.Latency)
+		}
+		lines = append(lines, fmt.Sprintf("Warning: on %s, %s (%s), which keeps C6 from being entered even if enabled.", cpus, limit, c.Source))
+	}
+	return lines
+}
+
+// cmdlineStatus warns about kernel command line parameters that may override
+// the available settings, e.g. idle=poll for C6 C-state, so that a value that
+// does not stick can be explained.
+func cmdlineStatus() []string {
+	params, err := kernel.CommandLine()
+	if err != nil {
+		return []string{fmt.Sprintf("Error while obtaining the kernel command line: %v", err)}
+	}
+
+	// Settings sharing a parameter are reported together.
+	var found []kernel.Parameter
+	overridden := map[string][]string{}
+	for _, s := range setting.All() {
+		if s.Available() != nil {
+			continue
+		}
+		for _, name := range setting.KernelParameters(s) {
+			p, ok := kernel.Lookup(params, name)
+			if !ok {
+				continue
+			}
+			if overridden[p.Name] == nil {
+				found = append(found, p)
+			}
+			overridden[p.Name] = append(overridden[p.Name], s.Name())
+		}
+	}
+
+	var lines []string
+	for _, p := range found {
+		lines = append(lines, fmt.Sprintf("Warning: the kernel command line has %s, which may override %s.", p, strings.Join(overridden[p.Name], ", ")))
+	}
+	return lines
+}
+
+// vulnerabilitiesStatus summarizes the CPU vulnerability mitigations, as
+// reported by the kernel, naming the vulnerabilities left unmitigated. With
+// verbose, what the kernel reports about the ones affecting the processor
+// follows.
+func vulnerabilitiesStatus() []string {
+	if !vulnerabilities.Available() {
+		return nil
+	}
+	vulns, err := vulnerabilities.List()
+	if err != nil {
+		return []string{fmt.Sprintf("Error while obtaining CPU vulnerabilities: %v", err)}
+	}
+
+	counts := map[string]int{}
+	var vulnerable []string
+	for _, v := range vulns {
+		counts[v.State]++
+		if v.State == vulnerabilities.Vulnerable {
+			vulnerable = append(vulnerable, v.Name)
+		}
+	}
+	line := fmt.Sprintf("CPU vulnerabilities: %d not affected, %d mitigated, %d vulnerable", counts[vulnerabilities.NotAffected], counts[vulnerabilities.Mitigated], counts[vulnerabilities.Vulnerable])
+	if len(vulnerable) > 0 {
+		line += fmt.Sprintf(" (%s)", strings.Join(vulnerable, ", "))
+	}
+	if counts[vulnerabilities.Unknown] > 0 {
+		line += fmt.Sprintf(", %d unknown", counts[vulnerabilities.Unknown])
+	}
+	lines := []string{line + "."}
+
+	if verbose {
+		for _, v := range vulns {
+			if v.State == vulnerabilities.NotAffected {
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("  %s: %s", v.Name, v.Details))
+		}
+	}
+	return lines
+}
+
+// powerDaemonsStatus warns about power management daemons that may revert our
+// changes.
+func powerDaemonsStatus() []string {
+	daemons := powerd.Conflicting()
+	if len(daemons) == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("Warning: %s may revert changes to processor boosting and other settings; consider masking them, or running ryzen-stabilizator after them.", strings.Join(daemons, ", "))}
+}
+
+// mceStatus reports the number of machine check exceptions since boot.
+func mceStatus() []string {
+	if !mce.Available() {
+		return nil
+	}
+	count, err := mce.Count()
+	if err != nil {
+		return []string{fmt.Sprintf("Error while obtaining machine check exception count: %v", err)}
+	}
+	return []string{fmt.Sprintf("Machine check exceptions (MCE) since boot: %d.", count)}
+}
+
+// kernelLogStatus counts the messages in the kernel log that often come with
+// instability, e.g. machine checks and segfaults. Telling them apart is a
+// heuristic, so it is labeled as such.
+func kernelLogStatus() []string {
+	counts, err := mce.LogSignatures()
+	if os.IsPermission(err) {
+		// The kernel log can only be read as root on most systems.
+		return nil
+	}
+	if err != nil {
+		return []string{fmt.Sprintf("Error while obtaining kernel log: %v", err)}
+	}
+	var found []string
+	for _, c := range counts {
+		if c.Count > 0 {
+			found = append(found, fmt.Sprintf("%d %s", c.Count, c.Signature))
+		}
+	}
+	if len(found) == 0 {
+		found = []string{"none"}
+	}
+	return []string{fmt.Sprintf("Kernel log messages typical of instability (heuristic): %s.", strings.Join(found, ", "))}
+}
+
+// amdPStateStatus reports the amd_pstate operation mode.
+func amdPStateStatus() []string {
+	if !amdpstate.Available() {
+		return nil
+	}
+	mode, err := amdpstate.Mode()
+	if err != nil {
+		return []string{fmt.Sprintf("Error while obtaining amd_pstate mode: %v", err)}
+	}
+	lines := []string{fmt.Sprintf("amd_pstate driver is in %s mode.", strings.ToUpper(mode))}
+	if mode == amdpstate.Active {
+		lines = append(lines, "Note: in active mode the firmware picks frequencies based on the energy performance preference, so the cpufreq governor and frequency limits behave differently.")
+	}
+	return lines
+}
+
+const (
+	// boostTolerance is how far, in MHz, the boost ceiling may be from the
+	// stock maximum boost frequency and still be considered stock.
+	boostTolerance = 25
+	// maxBoostOverride is the largest boost override PBO allows, in MHz.
+	maxBoostOverride = 200
+)
+
+// boostCeilingSource tells what sets the boost ceiling, given the stock
+// maximum boost frequency: "stock", the PBO boost override, e.g. "PBO +200
+// MHz", or a manual overclock, beyond what PBO allows.
+func boostCeilingSource(ceiling, rated int) string {
+	switch d := ceiling - rated; {
+	case d < -boostTolerance:
+		return "below stock"
+	case d <= boostTolerance:
+		return "stock"
+	case d <= maxBoostOverride:
+		return fmt.Sprintf("PBO +%d MHz", d)
+	}
+	return "manual OC"
+}
+
+// smuReadError describes a failure to obtain what, e.g. the temperature,
+// from the SMU. If it kept failing transiently, even after retrying, it is
+// only reported as unavailable, as that is expected once in a while.
+func smuReadError(what string, err error) string {
+	if errors.Is(err, smu.ErrUnavailable) || errors.Is(err, smu.ErrTimeout) {
+		return fmt.Sprintf("%s is unavailable (%v).", capitalize(what), err)
+	}
+	return fmt.Sprintf("Error while obtaining %s: %v", what, err)
+}
+
+// boostLimitStatus reports the boost frequency ceiling, according to the SMU,
+// and what sets it, compared to the stock maximum boost frequency.
+func boostLimitStatus() []string {
+	if !smu.Available() {
+		return []string{"Boost frequency ceiling is unknown (SMU unavailable)."}
+	}
+	limit, err := smu.BoostLimit()
+	switch {
+	case errors.Is(err, smu.ErrUnsupported):
+		// The SMU of this processor does not tell us the ceiling.
+		return []string{"Boost frequency ceiling is unknown (unsupported by the SMU of this processor)."}
+	case err != nil:
+		return []string{smuReadError("boost frequency ceiling", err)}
+	}
+	stock, err := boosting.StockMaxBoost()
+	if err != nil {
+		return []string{fmt.Sprintf("Boost frequency ceiling is %d MHz (source unknown).", limit)}
+	}
+	return []string{fmt.Sprintf("Boost frequency ceiling is %d MHz (stock max boost %d MHz; %s).", limit, stock, boostCeilingSource(limit, stock))}
+}
+
+// temperatureStatus reports the current temperature and the thermal limit,
+// according to the SMU.
+func temperatureStatus() []string {
+	if !smu.Available() {
+		return nil
+	}
+	temp, err := smu.Temperature()
+	if err != nil {
+		return []string{smuReadError("temperature", err)}
+	}
+	limit := "unavailable"
+	if tjMax, err := pmtable.Value(pmtable.THMLimit); err == nil {
+		limit = fmt.Sprintf("%.0f °C", tjMax)
+	}
+	lines := []string{fmt.Sprintf("Temperature (Tctl) is %.1f °C (thermal limit %s).", temp, limit)}
+
+	// The SoC temperature is that of the IOD on chiplet processors, which
+	// matters for memory and fabric tuning.
+	soc, err := pmtable.Value(pmtable.SOCTemp)
+	switch {
+	case errors.Is(err, pmtable.ErrUnsupported):
+		lines = append(lines, "SoC (IOD) temperature is unavailable for this PM table version.")
+	case err != nil:
+		lines = append(lines, smuReadError("SoC temperature", err))
+	default:
+		lines = append(lines, fmt.Sprintf("SoC (IOD) temperature is %.1f °C.", soc))
+	}
+
+	ccds, err := smu.CCDTemperatures()
+	if err != nil {
+		return append(lines, smuReadError("CCD temperatures", err))
+	}
+	for _, t := range ccds {
+		// Numbered from 1, like k10temp does, by physical index, so
+		// that the numbers do not shift when a CCD is fused off.
+		lines = append(lines, fmt.Sprintf("Temperature (Tccd%d) is %.1f °C.", t.CCD+1, t.Celsius))
+	}
+	return lines
+}
+
+// dramStatus reports the memory speed and primary timings, according to the
+// memory controller.
+func dramStatus() []string {
+	if !smu.Available() {
+		return nil
+	}
+	dram, err := smu.DRAM()
+	switch {
+	case errors.Is(err, smu.ErrUnsupported):
+		return []string{"DRAM configuration is unavailable for this processor."}
+	case err != nil:
+		return []string{smuReadError("DRAM configuration", err)}
+	}
+	gdm := "disabled"
+	if dram.GearDown {
+		gdm = "enabled"
+	}
+	return []string{fmt.Sprintf("DRAM is running at %d MT/s, %d-%d-%d-%d (tCL-tRCDRD-tRP-tRAS), %dT, gear down mode %s.",
+		dram.Speed, dram.CL, dram.RCDRD, dram.RP, dram.RAS, dram.CommandRate, gdm)}
+}
+
+// tdpStatus reports the rated TDP of the processor and, if the SMU tells us,
+// the current package power limit relative to it, so that changes to the
+// power limits can be put in context.
+func tdpStatus() []string {
+	tdp, err := cpuinfo.RatedTDP()
+	if err != nil {
+		return []string{"Rated TDP is unavailable."}
+	}
+	line := fmt.Sprintf("Rated TDP is %d W", tdp)
+	if smu.Available() {
+		if ppt, err := pmtable.Value(pmtable.PPTLimit); err == nil {
+			line += fmt.Sprintf("; package power limit (PPT) is %.0f W, %.2fx the TDP", ppt, ppt/float64(tdp))
+		}
+	}
+	return []string{line + "."}
+}
+
+// powerStatus reports the current package power, preferably from RAPL, or
+// otherwise from the SMU.
+func powerStatus() []string {
+	var watts float64
+	var err error
+	switch {
+	case rapl.Available():
+		watts, err = rapl.PackagePower(powerSampleInterval)
+	case smu.Available():
+		watts, err = pmtable.Value(pmtable.PPTValue)
+		if errors.Is(err, pmtable.ErrUnsupported) {
+			// The layout of the PM table of this processor is unknown.
+			return nil
+		}
+	default:
+		return nil
+	}
+	if err != nil {
+		return []string{smuReadError("package power", err)}
+	}
+	return []string{fmt.Sprintf("Package power is %.1f W.", watts)}
+}
+
+// showStatus displays a table with the current status, if available, of
+// every registered setting, e.g. C6 C-state, processor boosting and address
+// space layout randomization (ASLR), along with the configured one if cfg is
+// not nil. It is followed by other relevant information about the processor.
+// The reads are independent, so they are performed concurrently.
+func showStatus(cfg *configuration) {
+	table := make(chan []string, 1)
+	go func() {
+		table <- settingsTable(compareSettings(cfg))
+	}()
+
+	// The warnings go first, but are read along with the rest.
+	warnings := make(chan []string, 1)
+	go func() {
+		warnings <- collectStatus([]statusRead{
+			{"kernel lockdown mode", lockdownStatus},
+			{"microcode revisions", microcodeStatus},
+			{"power management daemons", powerDaemonsStatus},
+			{"PM QoS latency constraints", latencyStatus},
+			{"kernel command line", cmdlineStatus},
+		})
+	}()
+	reads := []statusRead{
+		{"preferred cores", preferredCoresStatus},
+		{"machine check exception count", mceStatus},
+		{"kernel log", kernelLogStatus},
+		{"amd_pstate mode", amdPStateStatus},
+		{"boost frequency ceiling", boostLimitStatus},
+		{"temperature", temperatureStatus},
+		{"rated TDP", tdpStatus},
+		{"package power", powerStatus},
+		{"DRAM configuration", dramStatus},
+		{"CPU vulnerabilities", vulnerabilitiesStatus},
+	}
+	if verbose {
+		reads = append(reads, statusRead{"cpuidle statistics", idleStatus})
+	}
+	info := collectStatus(reads)
+
+	fmt.Println("")
+	for _, lines := range [][]string{<-warnings, <-table, info} {
+		for _, line := range lines {
+			fmt.Println(line)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRunConcurrentlySkipsRunningSource(t *testing.T) {
+	hang := make(chan struct{})
+	defer close(hang)
+
+	errs := runConcurrently([]string{"test hang", "test quick"}, []func(){
+		func() { <-hang },
+		func() {},
+	}, 50*time.Millisecond)
+	if errs[0] != errTimedOut || errs[1] != nil {
+		t.Fatalf("first run: got %v, want [%v <nil>]", errs, errTimedOut)
+	}
+
+	ran := false
+	errs = runConcurrently([]string{"test hang", "test quick"}, []func(){
+		func() { ran = true },
+		func() {},
+	}, 50*time.Millisecond)
+	if errs[0] != errStillRunning || errs[1] != nil {
+		t.Fatalf("second run: got %v, want [%v <nil>]", errs, errStillRunning)
+	}
+	if ran {
+		t.Fatal("second read of a source still running was performed")
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sysfs
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// root is where sysfs is mounted.
+	root = "/sys/"
+	// blockPrefix has the symlinks to the block devices, under
+	// devicesPrefix.
+	blockPrefix   = "/sys/block/"
+	devicesPrefix = "/sys/devices/"
+
+	// AllowedPrefixes lists the parts of sysfs that may be written to, for
+	// safety: block device queues, memory management and the CPUs. Anything
+	// else, e.g. device power controls, is refused.
+	AllowedPrefixes = []string{
+		"/sys/block/",
+		"/sys/kernel/mm/",
+		"/sys/devices/system/cpu/",
+	}
+
+	// AllowedFiles lists the single files that may be written to besides
+	// those under AllowedPrefixes: the module parameters relevant to power
+	// management. The other module parameters, which may e.g. disable
+	// security checks of a driver, are refused.
+	AllowedFiles = []string{
+		"/sys/module/pcie_aspm/parameters/policy",
+		"/sys/module/workqueue/parameters/power_efficient",
+	}
+
+	// ErrNotAllowed indicates a path outside of AllowedPrefixes.
+	ErrNotAllowed = errors.New("path not allowed")
+)
+
+// CheckPath returns nil if path is an absolute path under /sys, within one of
+// the AllowedPrefixes or one of the AllowedFiles, or an error explaining why
+// not otherwise. Paths are
+// checked once cleaned, so that "/sys/block/../firmware" is refused, and, if
+// they exist, once their symlinks are resolved too, so that a symlink cannot
+// lead elsewhere.
+func CheckPath(path string) error {
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("%q is not an absolute path", path)
+	}
+	clean := filepath.Clean(path)
+	if !allowed(clean) {
+		return fmt.Errorf("%w: %q is not under %s", ErrNotAllowed, path, allowedList())
+	}
+	resolved, err := resolve(clean)
+	if err != nil {
+		return fmt.Errorf("unable to resolve %q: %v", path, err)
+	}
+	// The block devices in /sys/block are symlinks to their device, e.g.
+	// /sys/devices/pci0000:00/0000:00:01.1/0000:01:00.0/nvme/nvme0/nvme0n1.
+	blockDevice := strings.HasPrefix(clean, blockPrefix) && strings.HasPrefix(resolved, devicesPrefix) && strings.Contains(resolved, "/block/")
+	if !allowed(resolved) && !blockDevice {
+		return fmt.Errorf("%w: %q resolves to %q, which is not under %s", ErrNotAllowed, path, resolved, allowedList())
+	}
+	return nil
+}
+
+// resolve returns path with its symlinks resolved. A path that does not
+// exist, e.g. when checking a config file on another machine, is resolved as
+// far as it exists, so that its existing directories cannot be symlinks
+// leading elsewhere either; writing to it is then reported by Write.
+func resolve(path string) (string, error) {
+	var rest []string
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, rest...)...), nil
+		}
+		parent := filepath.Dir(path)
+		if !os.IsNotExist(err) || parent == path {
+			return "", err
+		}
+		rest = append([]string{filepath.Base(path)}, rest...)
+		path = parent
+	}
+}
+
+// allowed returns whether the given clean path is within one of the
+// AllowedPrefixes or one of the AllowedFiles.
+func allowed(path string) bool {
+	if !strings.HasPrefix(path, root) {
+		return false
+	}
+	for _, prefix := range AllowedPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	for _, file := range AllowedFiles {
+		if path == file {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedList returns the AllowedPrefixes and AllowedFiles, for reporting.
+func allowedList() string {
+	return strings.Join(append(append([]string{}, AllowedPrefixes...), AllowedFiles...), ", ")
+}
+
+// Read returns the value of the given sysfs file. For files listing every
+// accepted value with the current one in brackets, e.g. the I/O scheduler in
+// "mq-deadline [none]", only the current one is returned.
+func Read(path string) (string, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	value := strings.TrimSpace(string(buf))
+	for _, f := range strings.Fields(value) {
+		if strings.HasPrefix(f, "[") && strings.HasSuffix(f, "]") {
+			return strings.Trim(f, "[]"), nil
+		}
+	}
+	return value, nil
+}
+
+// Write writes value to the given sysfs file, which requires root, then reads
+// it back to make sure the kernel took it. The path must pass CheckPath.
+func Write(path, value string) error {
+	if err := CheckPath(path); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, []byte(value), 0644); err != nil {
+		return err
+	}
+	current, err := Read(path)
+	if err != nil {
+		return fmt.Errorf("unable to read back %s: %v", path, err)
+	}
+	if current != value {
+		return fmt.Errorf("%s is %q after writing %q", path, current, value)
+	}
+	return nil
+}
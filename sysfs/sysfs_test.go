@@ -0,0 +1,139 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sysfs
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeSysfs makes CheckPath use a sysfs tree in a temporary directory, with
+// the same layout as the real one, and returns where it is, along with the
+// function undoing it. The tree has a block device linking to its device,
+// and symlinks from allowed directories to elsewhere.
+func fakeSysfs(t *testing.T) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "sysfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// TempDir may itself be behind a symlink, e.g. on macOS.
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		t.Fatal(err)
+	}
+	sys := dir + "/sys/"
+	for _, d := range []string{
+		"block",
+		"kernel/mm/transparent_hugepage",
+		"devices/system/cpu/cpu0",
+		"devices/pci0000:00/nvme/block/nvme0n1/queue",
+		"devices/platform/i8042",
+		"module/pcie_aspm/parameters",
+		"module/usbcore/parameters",
+		"firmware/acpi",
+	} {
+		if err := os.MkdirAll(sys+d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"block/nvme0n1":                    "../devices/pci0000:00/nvme/block/nvme0n1",
+		"kernel/mm/firmware":               "../../firmware",
+		"devices/system/cpu/platform":      "../../platform",
+		"block/i8042":                      "../devices/platform/i8042",
+		"kernel/mm/transparent_hugepage/x": "../../../module/usbcore/parameters",
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, sys+link); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	savedRoot, savedBlock, savedDevices := root, blockPrefix, devicesPrefix
+	savedPrefixes, savedFiles := AllowedPrefixes, AllowedFiles
+	root, blockPrefix, devicesPrefix = sys, sys+"block/", sys+"devices/"
+	AllowedPrefixes = []string{sys + "block/", sys + "kernel/mm/", sys + "devices/system/cpu/"}
+	AllowedFiles = []string{sys + "module/pcie_aspm/parameters/policy"}
+	return dir, func() {
+		root, blockPrefix, devicesPrefix = savedRoot, savedBlock, savedDevices
+		AllowedPrefixes, AllowedFiles = savedPrefixes, savedFiles
+		os.RemoveAll(dir)
+	}
+}
+
+func TestCheckPath(t *testing.T) {
+	dir, cleanup := fakeSysfs(t)
+	defer cleanup()
+	tests := []struct {
+		path    string
+		allowed bool
+	}{
+		{"/sys/kernel/mm/transparent_hugepage/enabled", true},
+		{"/sys/devices/system/cpu/cpu0/online", true},
+		{"/sys/block/nvme0n1/queue/scheduler", true},
+		{"/sys/module/pcie_aspm/parameters/policy", true},
+		// Not existing yet, which Write reports on its own.
+		{"/sys/kernel/mm/ksm/run", true},
+
+		{"/sys/firmware/acpi/x", false},
+		{"/sys/module/usbcore/parameters/autosuspend", false},
+		{"/sys/module/pcie_aspm/parameters/policyx", false},
+		{"/sys/module/pcie_aspm/parameters", false},
+		{"/sys/blockx/nvme0n1/queue/scheduler", false},
+		{"/sys/block", false},
+		{"/sys/block/../firmware/acpi/x", false},
+		{"/sys/kernel/mm/../../firmware/acpi/x", false},
+		// Symlinks resolving out of the allowed directories.
+		{"/sys/kernel/mm/firmware/acpi/x", false},
+		{"/sys/devices/system/cpu/platform/x", false},
+		{"/sys/block/i8042/x", false},
+		{"/sys/kernel/mm/transparent_hugepage/x/autosuspend", false},
+	}
+	for _, tt := range tests {
+		err := CheckPath(dir + tt.path)
+		if tt.allowed && err != nil {
+			t.Errorf("CheckPath(%q) = %v, want nil", tt.path, err)
+		}
+		if !tt.allowed && !errors.Is(err, ErrNotAllowed) {
+			t.Errorf("CheckPath(%q) = %v, want %v", tt.path, err, ErrNotAllowed)
+		}
+	}
+}
+
+func TestCheckPathRelative(t *testing.T) {
+	_, cleanup := fakeSysfs(t)
+	defer cleanup()
+	for _, path := range []string{"sys/block/nvme0n1/queue/scheduler", "../sys/kernel/mm/x", ""} {
+		if err := CheckPath(path); err == nil {
+			t.Errorf("CheckPath(%q) = nil, want an error", path)
+		}
+	}
+}
+
+func TestCheckPathReal(t *testing.T) {
+	for _, path := range []string{
+		"/sys/module/usbcore/parameters/autosuspend",
+		"/sys/blockx/sda/queue/scheduler",
+		"/sys/block/../firmware/x",
+		"/proc/sys/kernel/randomize_va_space",
+		"/sys",
+	} {
+		if err := CheckPath(path); !errors.Is(err, ErrNotAllowed) {
+			t.Errorf("CheckPath(%q) = %v, want %v", path, err, ErrNotAllowed)
+		}
+	}
+}
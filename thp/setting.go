@@ -0,0 +1,54 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package thp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/setting"
+)
+
+// usualModes are the modes accepted by most kernels.
+var usualModes = []string{Always, Madvise, Never}
+
+func init() {
+	setting.Register(&setting.Sysfs{
+		Key:             "thp",
+		Label:           "transparent hugepages",
+		Path:            enabledFile,
+		Accepted:        usualModes,
+		Check:           validateMode,
+		CaseInsensitive: true,
+		Availability:    Available,
+		Persists:        "lost at reboot, unless set with the transparent_hugepage= kernel parameter",
+		KernelParams:    []string{"transparent_hugepage"},
+		Order:           setting.OrderTHP,
+	})
+}
+
+// validateMode checks mode is one of the modes the kernel accepts. If they
+// cannot be read, e.g. when checking a config file on another machine, the
+// usual modes are accepted.
+func validateMode(mode string) error {
+	if Available() == nil {
+		return Validate(mode)
+	}
+	for _, m := range usualModes {
+		if m == mode {
+			return nil
+		}
+	}
+	return fmt.Errorf("expected one of %s", strings.Join(usualModes, ", "))
+}
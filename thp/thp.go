@@ -0,0 +1,108 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package thp
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+const (
+	enabledFile = "/sys/kernel/mm/transparent_hugepage/enabled"
+
+	// Always means hugepages are used for every suitable mapping.
+	Always = "always"
+	// Madvise means hugepages are only used for mappings that ask for them
+	// with madvise(MADV_HUGEPAGE).
+	Madvise = "madvise"
+	// Never means hugepages are not used.
+	Never = "never"
+)
+
+var (
+	// ErrUnsupported indicates the kernel has no transparent hugepage
+	// support.
+	ErrUnsupported = errors.New("transparent hugepages not supported by the kernel")
+)
+
+// Available returns nil if transparent hugepages can be managed, or
+// ErrUnsupported otherwise.
+func Available() error {
+	if _, err := os.Stat(enabledFile); err != nil {
+		return ErrUnsupported
+	}
+	return nil
+}
+
+// readModes returns the modes the kernel accepts, and the current one, which
+// it lists in brackets, e.g. "always [madvise] never".
+func readModes() ([]string, string, error) {
+	value, err := ioutil.ReadFile(enabledFile)
+	if err != nil {
+		return nil, "", err
+	}
+	var modes []string
+	current := ""
+	for _, f := range strings.Fields(string(value)) {
+		if strings.HasPrefix(f, "[") && strings.HasSuffix(f, "]") {
+			f = strings.Trim(f, "[]")
+			current = f
+		}
+		modes = append(modes, f)
+	}
+	if current == "" {
+		return nil, "", fmt.Errorf("no current mode in %s", enabledFile)
+	}
+	return modes, current, nil
+}
+
+// Modes returns the modes accepted by the kernel, e.g. Always, Madvise and
+// Never.
+func Modes() ([]string, error) {
+	modes, _, err := readModes()
+	return modes, err
+}
+
+// Mode returns the current transparent hugepage mode.
+func Mode() (string, error) {
+	_, current, err := readModes()
+	return current, err
+}
+
+// Validate returns nil if mode is one of the modes accepted by the kernel, or
+// an error listing them otherwise.
+func Validate(mode string) error {
+	modes, err := Modes()
+	if err != nil {
+		return err
+	}
+	for _, m := range modes {
+		if m == mode {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid transparent hugepage mode %q; expected one of %s", mode, strings.Join(modes, ", "))
+}
+
+// Set changes the transparent hugepage mode, which requires root. The mode
+// must be one of the ones accepted by the kernel.
+func Set(mode string) error {
+	if err := Validate(mode); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(enabledFile, []byte(mode), 0644)
+}
@@ -0,0 +1,94 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vulnerabilities
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	vulnerabilitiesDir = "/sys/devices/system/cpu/vulnerabilities"
+)
+
+// States of a CPU vulnerability, as summarized from what the kernel reports.
+const (
+	// NotAffected means the processor is not affected.
+	NotAffected = "not affected"
+	// Mitigated means the kernel mitigates the vulnerability.
+	Mitigated = "mitigated"
+	// Vulnerable means the processor is affected and not mitigated,
+	// e.g. with mitigations=off.
+	Vulnerable = "vulnerable"
+	// Unknown means the kernel reports something else, e.g. "Unknown:
+	// Dependent on hypervisor status".
+	Unknown = "unknown"
+)
+
+// Vulnerability is a CPU vulnerability the kernel knows about, e.g.
+// spectre_v2.
+type Vulnerability struct {
+	// Name is the name of the vulnerability, as in sysfs.
+	Name string
+	// State is one of NotAffected, Mitigated, Vulnerable or Unknown.
+	State string
+	// Details is what the kernel reports, e.g. "Mitigation: Retpolines".
+	Details string
+}
+
+// Available returns a boolean indicating whether the kernel reports CPU
+// vulnerabilities, which it does since 4.15.
+func Available() bool {
+	if _, err := os.Stat(vulnerabilitiesDir); err == nil {
+		return true
+	}
+	return false
+}
+
+// state summarizes what the kernel reports about a vulnerability.
+func state(details string) string {
+	switch {
+	case details == "Not affected":
+		return NotAffected
+	case strings.HasPrefix(details, "Mitigation"):
+		return Mitigated
+	case strings.HasPrefix(details, "Vulnerable"):
+		return Vulnerable
+	}
+	return Unknown
+}
+
+// List returns the CPU vulnerabilities the kernel knows about, in lexical
+// order of their names.
+func List() ([]Vulnerability, error) {
+	files, err := filepath.Glob(filepath.Join(vulnerabilitiesDir, "*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var vulns []Vulnerability
+	for _, f := range files {
+		value, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		details := strings.TrimSpace(string(value))
+		vulns = append(vulns, Vulnerability{Name: filepath.Base(f), State: state(details), Details: details})
+	}
+	return vulns, nil
+}
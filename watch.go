@@ -0,0 +1,93 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/boosting"
+)
+
+// showBoostResidency samples the boost residency of every CPU over interval
+// and displays it. Sampling blocks for the whole interval.
+func showBoostResidency(interval time.Duration) {
+	residency, err := boosting.BoostResidency(interval)
+	if err != nil {
+		fmt.Printf("Boost residency unavailable: %v.\n", err)
+		// Keep the pace of the watch loop regardless.
+		time.Sleep(interval)
+		return
+	}
+
+	cores := make([]string, 0, len(residency))
+	for _, r := range residency {
+		cores = append(cores, fmt.Sprintf("cpu%d %.1f%%", r.CPU, r.Percent))
+	}
+	fmt.Printf("Boost residency over the last %v: %s.\n", interval, strings.Join(cores, ", "))
+}
+
+// watch displays the current status every interval, along with how much time
+// each CPU spent boosting in the meantime, until the program is interrupted.
+func watch(interval time.Duration) {
+	for {
+		fmt.Printf("\n--- %s ---", time.Now().Format(time.RFC1123))
+		showStatus(nil)
+		showBoostResidency(interval)
+	}
+}
+
+// watchDrift displays the status every interval, compared to cfg, along with
+// the boost residency, like watch, without correcting anything, so that the
+// effect of a configuration just applied can be followed, until interrupted.
+// The configured settings the filter allows are reported as they drift from
+// cfg, and as they match it again.
+func watchDrift(cfg *configuration, filter *settingFilter, interval time.Duration) {
+	// Interrupting is the way out, so it is not an error.
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt)
+	go func() {
+		<-interrupted
+		fmt.Println("\nStopped watching; nothing was changed since applying.")
+		os.Exit(0)
+	}()
+
+	fmt.Printf("\nWatching every %v whether the configuration holds, without correcting it; press Ctrl+C to stop.\n", interval)
+	// When each setting was first noticed to differ from cfg.
+	drifted := map[string]time.Time{}
+	for {
+		showBoostResidency(interval)
+		now := time.Now()
+		for _, c := range compareSettings(cfg) {
+			name := c.setting.Name()
+			if !c.configured || !filter.allows(name) {
+				continue
+			}
+			since, known := drifted[name]
+			switch {
+			case c.mismatch() && !known:
+				drifted[name] = now
+				fmt.Printf("%s: Drift: %s is %s, instead of %s as configured.\n", now.Format(time.RFC3339), name, displayValue(c.setting, c.current), displayValue(c.setting, c.desired))
+			case !c.mismatch() && known && c.err == nil:
+				delete(drifted, name)
+				fmt.Printf("%s: %s matches the configuration again, after drifting for %v.\n", now.Format(time.RFC3339), name, now.Sub(since).Round(time.Second))
+			}
+		}
+		fmt.Printf("\n--- %s ---", now.Format(time.RFC1123))
+		showStatus(cfg)
+	}
+}
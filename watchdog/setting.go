@@ -0,0 +1,34 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watchdog
+
+import (
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/setting"
+)
+
+func init() {
+	setting.Register(&setting.Toggle{
+		Key:          "nmiwatchdog",
+		Label:        "NMI watchdog",
+		Availability: Available,
+		Enable:       Enable,
+		Disable:      Disable,
+		IsEnabled:    Enabled,
+		Persists:     "lost at reboot, unless set in /etc/sysctl.d (kernel.nmi_watchdog)",
+		BootDefault:  setting.Enabled,
+		Touches:      nmiWatchdogFile,
+		KernelParams: []string{"nmi_watchdog", "nowatchdog"},
+		Order:        setting.OrderNMIWatchdog,
+	})
+}
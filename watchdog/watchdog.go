@@ -0,0 +1,86 @@
+// Copyright 2018 Sergio Correia <sergio@correia.cc>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watchdog
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/qrwteyrutiyoup/ryzen-stabilizator/kernel"
+)
+
+const (
+	nmiWatchdogFile = "/proc/sys/kernel/nmi_watchdog"
+)
+
+var (
+	// ErrUnsupported indicates the kernel was built without the NMI
+	// watchdog, i.e. the hardlockup detector.
+	ErrUnsupported = errors.New("NMI watchdog not supported by the kernel")
+)
+
+// Available returns nil if the NMI watchdog can be managed, or ErrUnsupported
+// otherwise.
+func Available() error {
+	if _, err := os.Stat(nmiWatchdogFile); err != nil {
+		return ErrUnsupported
+	}
+	return nil
+}
+
+// Enabled returns a boolean indicating whether the NMI watchdog is enabled or
+// not.
+func Enabled() (bool, error) {
+	value, err := ioutil.ReadFile(nmiWatchdogFile)
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(string(value)) != "0", nil
+}
+
+// changeWatchdog either enables or disables the NMI watchdog, depending on
+// whether the provided parameter is true or false, respectively. The value is
+// read back, as the kernel may refuse to enable it, e.g. when the performance
+// counters it needs are in use.
+func changeWatchdog(enable bool) error {
+	value := "0"
+	if enable {
+		value = "1"
+	}
+	if err := ioutil.WriteFile(nmiWatchdogFile, []byte(value), 0644); err != nil {
+		return kernel.SysctlWriteError(err)
+	}
+	enabled, err := Enabled()
+	if err != nil {
+		return err
+	}
+	if enabled != enable {
+		return fmt.Errorf("NMI watchdog did not change as requested")
+	}
+	return nil
+}
+
+// Enable enables the NMI watchdog.
+func Enable() error {
+	return changeWatchdog(true)
+}
+
+// Disable disables the NMI watchdog, which lowers the latency spikes caused
+// by its periodic interrupts.
+func Disable() error {
+	return changeWatchdog(false)
+}